@@ -0,0 +1,108 @@
+package requests
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// BackoffStrategy creates the Backoffer of one request, it is called once per Retryer.Do
+type BackoffStrategy func() Backoffer
+
+// Jitter randomizes the exponential backoff to avoid synchronized retries between clients
+type Jitter int
+
+const (
+	// NoJitter sleeps the exact exponential delay
+	NoJitter Jitter = iota
+	// FullJitter sleeps a random delay in [0, d)
+	FullJitter
+	// EqualJitter sleeps a random delay in [d/2, d)
+	EqualJitter
+	// DecorrelatedJitter sleeps a random delay in [base, 3*previous), independent of the attempt number
+	DecorrelatedJitter
+)
+
+// WithBackoff sets the backoff strategy between retries
+func WithBackoff(strategy BackoffStrategy) RetryerOption {
+	return func(option *retryerOption) {
+		option.backoff = strategy
+	}
+}
+
+// ExponentialBackoff doubles the delay from base on each attempt, capped at max (no cap if max is 0)
+func ExponentialBackoff(base, max time.Duration, jitter Jitter) BackoffStrategy {
+	return func() Backoffer {
+		var attempts int
+		prev := base
+		return Backoff(func(*http.Response) time.Duration {
+			attempts++
+			if jitter == DecorrelatedJitter {
+				upper := maxDuration
+				if prev < maxDuration/3 {
+					upper = 3 * prev
+				}
+				prev = capDelay(base+randDuration(upper-base), max)
+				return prev
+			}
+
+			d := capDelay(exponential(base, attempts), max)
+			switch jitter {
+			case FullJitter:
+				return randDuration(d)
+			case EqualJitter:
+				return d/2 + randDuration(d-d/2)
+			default:
+				return d
+			}
+		})
+	}
+}
+
+// ConstantBackoff always sleeps d
+func ConstantBackoff(d time.Duration) BackoffStrategy {
+	return func() Backoffer {
+		return Backoff(func(*http.Response) time.Duration {
+			return d
+		})
+	}
+}
+
+// LinearBackoff sleeps step*attempts, capped at max (no cap if max is 0)
+func LinearBackoff(step, max time.Duration) BackoffStrategy {
+	return func() Backoffer {
+		var attempts int
+		return Backoff(func(*http.Response) time.Duration {
+			attempts++
+			return capDelay(step*time.Duration(attempts), max)
+		})
+	}
+}
+
+// exponential returns base*2^(attempts-1) without overflowing
+func exponential(base time.Duration, attempts int) time.Duration {
+	d := base
+	for i := 1; i < attempts; i++ {
+		if d > maxDuration/2 {
+			return maxDuration
+		}
+		d *= 2
+	}
+	return d
+}
+
+const maxDuration = time.Duration(1<<63 - 1)
+
+func capDelay(d, max time.Duration) time.Duration {
+	if max > 0 && (d > max || d < 0) {
+		return max
+	}
+	return d
+}
+
+func randDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
@@ -12,15 +12,21 @@ type Request struct {
 	method  stringer
 	baseUrl stringer
 	path    stringer
-	body    stringer
+	body    bodyFunc
+	form    stringerMap // values of FormBody
 	header  stringerMap
 	query   stringerMap
 	secrets stringerMap
 
-	timeout time.Duration
+	timeout         time.Duration
+	statusValidator StatusValidator
+	idempotent      bool
+	route           string
+	sequentialBody  bool // the body can not be read by concurrent attempts
 
-	err  error
-	doer Doer // this doer should do all error handling, if it returns err=nil we are ready to use the payload
+	err    error
+	logger RequestLogger
+	doer   Doer // this doer should do all error handling, if it returns err=nil we are ready to use the payload
 }
 
 type Doer interface {
@@ -44,13 +50,19 @@ func (d defaultDoer) Do(r *http.Request) (*http.Response, error) {
 	resp, err := d.doer.Do(r)
 	if err != nil {
 		return nil, err
-	} else if resp.StatusCode != 200 {
+	} else if !statusValidator(r)(resp.StatusCode) {
+		err := newStatusError(resp)
 		_ = drain(resp.Body)
-		return nil, fmt.Errorf("invalid status %s", resp.Status)
+		return nil, err
 	}
 	return resp, nil
 }
 
+// CheckStatus is a Middleware rejecting responses with a *StatusError, like the default doer of a *Request
+func CheckStatus(next Doer) Doer {
+	return &defaultDoer{doer: next}
+}
+
 // New creates a new *Request
 func New(url interface{}) *Request {
 	c := &Request{header: map[string]stringer{}, query: map[string]stringer{}, doer: &defaultDoer{doer: http.DefaultClient}, secrets: map[string]stringer{}}
@@ -75,6 +87,7 @@ func FromRawURL(rawUrl string) (*Request, error) {
 
 const (
 	applicationJSON = "application/json"
+	applicationForm = "application/x-www-form-urlencoded"
 )
 
 func (req *Request) toStringer(v interface{}) stringer {
@@ -137,8 +150,7 @@ func (req *Request) Timeout(d time.Duration) *Request {
 
 // Body set the http body
 func (req *Request) Body(contentType string, value interface{}) *Request {
-	req.body = req.toStringer(value)
-	return req.ContentType(contentType)
+	return req.replaceBody(stringBody(req.toStringer(value))).ContentType(contentType)
 }
 
 // Query sets a http query
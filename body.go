@@ -0,0 +1,139 @@
+package requests
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// maskedBinary replaces binary content when the request is written masked
+const maskedBinary = "[binary data]"
+
+// BodyReader sets a streamed body, open is called once per attempt so a Retryer can replay it.
+// The length is unknown, the body is sent chunked
+func (req *Request) BodyReader(open func() (io.ReadCloser, error)) *Request {
+	return req.replaceBody(binaryBody(func() (func() (io.ReadCloser, error), int64, error) {
+		return open, -1, nil
+	}))
+}
+
+// BodyBytes sets a binary body without copying b, it must not be modified until the request is done
+func (req *Request) BodyBytes(b []byte) *Request {
+	return req.replaceBody(binaryBody(func() (func() (io.ReadCloser, error), int64, error) {
+		return bytesReader(b), int64(len(b)), nil
+	}))
+}
+
+// BodyFile streams the file at path, it is reopened for every attempt
+func (req *Request) BodyFile(path string) *Request {
+	return req.replaceBody(binaryBody(func() (func() (io.ReadCloser, error), int64, error) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		return func() (io.ReadCloser, error) {
+			return os.Open(path)
+		}, info.Size(), nil
+	}))
+}
+
+// replaceBody sets the body, clearing the state of the previous one
+func (req *Request) replaceBody(body bodyFunc) *Request {
+	req.body, req.form, req.sequentialBody = body, nil, false
+	return req
+}
+
+// bodyFunc prepares the body of one request. getBody is called once per attempt,
+// size is -1 when the length is unknown
+type bodyFunc func(render func(stringer) string, masked bool) (getBody func() (io.ReadCloser, error), size int64, err error)
+
+func stringBody(s stringer) bodyFunc {
+	return func(render func(stringer) string, masked bool) (func() (io.ReadCloser, error), int64, error) {
+		b := []byte(render(s))
+		return bytesReader(b), int64(len(b)), nil
+	}
+}
+
+func bytesReader(b []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+}
+
+// binaryBody is never rendered, a placeholder is written when masked
+func binaryBody(prepare func() (func() (io.ReadCloser, error), int64, error)) bodyFunc {
+	return func(render func(stringer) string, masked bool) (func() (io.ReadCloser, error), int64, error) {
+		if masked {
+			return bytesReader([]byte(maskedBinary)), int64(len(maskedBinary)), nil
+		}
+		return prepare()
+	}
+}
+
+// formBody renders the values one by one, so secrets are masked before they are encoded
+func formBody(form stringerMap) bodyFunc {
+	return func(render func(stringer) string, masked bool) (func() (io.ReadCloser, error), int64, error) {
+		values := url.Values{}
+		for k, v := range form {
+			values.Set(k, render(v))
+		}
+		b := []byte(values.Encode())
+		return bytesReader(b), int64(len(b)), nil
+	}
+}
+
+// setBody sets the body of r, the first body is opened on the first read so a Retryer
+// resetting it through GetBody never opens it
+func setBody(r *http.Request, getBody func() (io.ReadCloser, error), size int64) {
+	r.ContentLength = size
+	if size == 0 {
+		r.Body = http.NoBody
+		r.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		return
+	}
+	r.Body = &lazyReadCloser{open: getBody}
+	r.GetBody = getBody
+}
+
+type lazyReadCloser struct {
+	open func() (io.ReadCloser, error)
+	mtx  sync.Mutex
+	rc   io.ReadCloser
+	err  error
+}
+
+func (l *lazyReadCloser) Read(p []byte) (int, error) {
+	l.mtx.Lock()
+	if l.rc == nil && l.err == nil {
+		l.rc, l.err = l.open()
+	}
+	rc, err := l.rc, l.err
+	l.mtx.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return rc.Read(p)
+}
+
+func (l *lazyReadCloser) Close() error {
+	l.mtx.Lock()
+	rc := l.rc
+	if rc == nil {
+		l.err = io.ErrClosedPipe
+	}
+	l.mtx.Unlock()
+	if rc == nil {
+		return nil
+	}
+	return rc.Close()
+}
+
+type countWriter int64
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	*c += countWriter(len(p))
+	return len(p), nil
+}
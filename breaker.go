@@ -0,0 +1,254 @@
+package requests
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type CircuitState int
+
+const (
+	StateClosed CircuitState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitOpenError is returned without calling the upstream while the circuit is open
+type CircuitOpenError struct {
+	Until time.Time // when the circuit will allow a probe
+}
+
+func (e *CircuitOpenError) Error() string {
+	return "circuit breaker is open"
+}
+
+const breakerBuckets = 10
+
+// CircuitBreaker is a Doer failing fast while the failure rate of the upstream is too high.
+// An attempt is failed when the RetryPolicy would retry it
+type CircuitBreaker struct {
+	doer   Doer
+	logger RequestLogger
+	policy RetryPolicy
+
+	window      time.Duration
+	failureRate float64
+	minRequests int
+	openTimeout time.Duration
+	probes      int
+
+	mtx               sync.Mutex
+	state             CircuitState
+	openedAt          time.Time
+	halfOpenProbes    int
+	halfOpenSuccesses int
+	buckets           [breakerBuckets]outcomeBucket
+}
+
+type outcomeBucket struct {
+	start             time.Time
+	success, failures int
+}
+
+type CircuitBreakerOption func(*CircuitBreaker)
+
+// WithFailureThreshold opens the circuit when the failure rate in the window reaches rate, given at least minRequests requests
+func WithFailureThreshold(rate float64, minRequests int) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.failureRate = rate
+		cb.minRequests = minRequests
+	}
+}
+
+// WithFailureWindow sets the rolling window used for the failure rate, it defaults to 10s.
+// Windows shorter than 10ms are raised to 10ms, the window is split in 10 buckets
+func WithFailureWindow(window time.Duration) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.window = window
+	}
+}
+
+// WithOpenTimeout sets how long the circuit stays open before probing the upstream
+func WithOpenTimeout(d time.Duration) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.openTimeout = d
+	}
+}
+
+// WithHalfOpenProbes sets the number of successful probes needed to close the circuit
+func WithHalfOpenProbes(n int) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.probes = n
+	}
+}
+
+// WithBreakerPolicy sets the policy classifying failed attempts, it defaults to DefaultRetryPolicy
+func WithBreakerPolicy(policy RetryPolicy) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.policy = policy
+	}
+}
+
+// NewCircuitBreaker wraps doer with a circuit breaker, state changes are reported to logger
+func NewCircuitBreaker(doer Doer, logger RequestLogger, opts ...CircuitBreakerOption) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		doer:        doer,
+		logger:      logger,
+		policy:      DefaultRetryPolicy,
+		window:      10 * time.Second,
+		failureRate: 0.5,
+		minRequests: 10,
+		openTimeout: 5 * time.Second,
+		probes:      1,
+	}
+	for _, opt := range opts {
+		opt(cb)
+	}
+	if cb.probes < 1 {
+		cb.probes = 1
+	}
+	if cb.window < breakerBuckets*time.Millisecond {
+		cb.window = breakerBuckets * time.Millisecond
+	}
+	return cb
+}
+
+// State returns the current state of the circuit
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+	return cb.state
+}
+
+func (cb *CircuitBreaker) Do(request *http.Request) (*http.Response, error) {
+	probe, change, err := cb.allow(time.Now())
+	cb.report(change)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cb.doer.Do(request)
+	if request.Context().Err() != nil {
+		// the caller gave up, it says nothing about the upstream
+		cb.abort(probe)
+		return resp, err
+	}
+	failed, _ := cb.policy(resp, err)
+	cb.report(cb.record(time.Now(), probe, failed, err))
+	return resp, err
+}
+
+func (cb *CircuitBreaker) allow(now time.Time) (probe bool, change *stateChange, err error) {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+
+	if cb.state == StateOpen {
+		if until := cb.openedAt.Add(cb.openTimeout); now.Before(until) {
+			return false, nil, &CircuitOpenError{Until: until}
+		}
+		change = cb.setState(StateHalfOpen, nil)
+	}
+
+	if cb.state == StateHalfOpen {
+		if cb.halfOpenProbes >= cb.probes {
+			return false, change, &CircuitOpenError{Until: now}
+		}
+		cb.halfOpenProbes++
+		return true, change, nil
+	}
+	return false, change, nil
+}
+
+func (cb *CircuitBreaker) abort(probe bool) {
+	if probe {
+		cb.mtx.Lock()
+		if cb.state == StateHalfOpen {
+			cb.halfOpenProbes--
+		}
+		cb.mtx.Unlock()
+	}
+}
+
+func (cb *CircuitBreaker) record(now time.Time, probe, failed bool, err error) *stateChange {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+
+	switch {
+	case cb.state == StateHalfOpen && probe:
+		if failed {
+			cb.openedAt = now
+			return cb.setState(StateOpen, err)
+		} else if cb.halfOpenSuccesses++; cb.halfOpenSuccesses >= cb.probes {
+			cb.buckets = [breakerBuckets]outcomeBucket{}
+			return cb.setState(StateClosed, nil)
+		}
+	case cb.state == StateClosed:
+		b := cb.bucket(now)
+		if failed {
+			b.failures++
+		} else {
+			b.success++
+		}
+		if total, failures := cb.totals(now); total >= cb.minRequests && float64(failures) >= cb.failureRate*float64(total) {
+			cb.openedAt = now
+			return cb.setState(StateOpen, err)
+		}
+	}
+	return nil
+}
+
+type stateChange struct {
+	from, to CircuitState
+	err      error
+}
+
+// setState must be called with cb.mtx held, the returned change is reported after unlocking
+func (cb *CircuitBreaker) setState(state CircuitState, err error) *stateChange {
+	change := &stateChange{from: cb.state, to: state, err: err}
+	cb.state = state
+	cb.halfOpenProbes, cb.halfOpenSuccesses = 0, 0
+	return change
+}
+
+// report logs a state change, it must be called without cb.mtx held as the logger may call State
+func (cb *CircuitBreaker) report(change *stateChange) {
+	if change != nil && cb.logger != nil {
+		cb.logger.Log(cb.logger.NextID(), change.err, fmt.Sprintf("circuit %s -> %s", change.from, change.to))
+	}
+}
+
+func (cb *CircuitBreaker) bucket(now time.Time) *outcomeBucket {
+	size := int64(cb.window / breakerBuckets)
+	idx := now.UnixNano() / size
+	start := time.Unix(0, idx*size)
+	b := &cb.buckets[idx%breakerBuckets]
+	if !b.start.Equal(start) {
+		*b = outcomeBucket{start: start}
+	}
+	return b
+}
+
+func (cb *CircuitBreaker) totals(now time.Time) (total, failures int) {
+	for _, b := range cb.buckets {
+		if now.Sub(b.start) < cb.window {
+			total += b.success + b.failures
+			failures += b.failures
+		}
+	}
+	return total, failures
+}
@@ -0,0 +1,105 @@
+package requests_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ajzo90/go-requests"
+	"github.com/matryer/is"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	is := is.New(t)
+	var (
+		healthy int32
+		calls   int32
+		events  []string
+	)
+	upstream := requests.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 1 {
+			return okDoer(r)
+		}
+		return &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway", Body: http.NoBody, Request: r}, nil
+	})
+	breakerLogger := requests.Logger(func(id int, err error, msg string) {
+		events = append(events, msg)
+	})
+	cb := requests.NewCircuitBreaker(upstream, breakerLogger,
+		requests.WithFailureThreshold(0.5, 4),
+		requests.WithFailureWindow(time.Minute),
+		requests.WithOpenTimeout(50*time.Millisecond),
+	)
+
+	for i := 0; i < 4; i++ {
+		resp, err := cb.Do(newTestRequest(t, context.Background(), "http://a.com"))
+		is.NoErr(err)
+		is.Equal(resp.StatusCode, http.StatusBadGateway)
+	}
+	is.Equal(cb.State(), requests.StateOpen)
+
+	_, err := cb.Do(newTestRequest(t, context.Background(), "http://a.com"))
+	var openErr *requests.CircuitOpenError
+	is.True(errors.As(err, &openErr))
+	is.Equal(atomic.LoadInt32(&calls), int32(4))
+
+	// the retryer fails fast on an open circuit
+	_, err = requests.NewGet("http://a.com").WithExtended(func(req *requests.ExtendedRequest) {
+		req.Doer(requests.NewRetryer(cb, logger))
+	}).ExecJSON()
+	is.True(errors.As(err, &openErr))
+
+	// a failed probe opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	_, err = cb.Do(newTestRequest(t, context.Background(), "http://a.com"))
+	is.NoErr(err)
+	is.Equal(cb.State(), requests.StateOpen)
+
+	// a successful probe closes it
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(60 * time.Millisecond)
+	resp, err := cb.Do(newTestRequest(t, context.Background(), "http://a.com"))
+	is.NoErr(err)
+	is.Equal(resp.StatusCode, http.StatusOK)
+	is.Equal(cb.State(), requests.StateClosed)
+
+	is.Equal(events, []string{
+		"circuit closed -> open",
+		"circuit open -> half-open",
+		"circuit half-open -> open",
+		"circuit open -> half-open",
+		"circuit half-open -> closed",
+	})
+}
+
+func TestCircuitBreaker_LoggerCallsState(t *testing.T) {
+	is := is.New(t)
+	var (
+		cb     *requests.CircuitBreaker
+		states []requests.CircuitState
+		ids    []int
+	)
+	cb = requests.NewCircuitBreaker(requests.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway", Body: http.NoBody, Request: r}, nil
+	}), requests.Logger(func(id int, err error, msg string) {
+		ids = append(ids, id)
+		states = append(states, cb.State()) // must not deadlock
+	}), requests.WithFailureThreshold(0.5, 1), requests.WithFailureWindow(time.Nanosecond))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = cb.Do(newTestRequest(t, context.Background(), "http://a.com"))
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock")
+	}
+	is.Equal(states, []requests.CircuitState{requests.StateOpen})
+	is.True(ids[0] != 0) // ids do not collide with the zero value
+}
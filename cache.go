@@ -0,0 +1,501 @@
+package requests
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStatusHeader is set on responses from the Cache to HIT, MISS, REVALIDATED or STALE
+const CacheStatusHeader = "X-Cache"
+
+const (
+	CacheHit         = "HIT"
+	CacheMiss        = "MISS"
+	CacheRevalidated = "REVALIDATED"
+	CacheStale       = "STALE"
+)
+
+// FromCache reports whether resp was served from the cache, including revalidated and stale responses
+func FromCache(resp *http.Response) bool {
+	switch resp.Header.Get(CacheStatusHeader) {
+	case CacheHit, CacheRevalidated, CacheStale:
+		return true
+	}
+	return false
+}
+
+// CacheEntry is a stored response
+type CacheEntry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	StoredAt   time.Time
+	Vary       http.Header // the request headers selected by the Vary response header
+}
+
+// CacheStorage stores the cache entries, it must be safe for concurrent use
+type CacheStorage interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+// Cache is an HTTP cache Doer (RFC 9111) for GET and HEAD requests.
+// It can be shared by callers with different credentials, so responses to requests with
+// Authorization or Cookie are only stored when marked public or s-maxage. It serves fresh responses from storage, revalidates stale ones with If-None-Match/If-Modified-Since,
+// and supports stale-while-revalidate and stale-if-error. A 304 is returned as the cached 200.
+// Wrap the transport rather than a status checking doer, so the 304s reach the cache:
+//
+//	requests.CheckStatus(requests.NewCache(http.DefaultClient, requests.NewMemoryCache(1000)))
+type Cache struct {
+	doer              Doer
+	storage           CacheStorage
+	revalidateTimeout time.Duration
+
+	mtx          sync.Mutex
+	revalidating map[string]bool
+}
+
+type CacheOption func(*Cache)
+
+// WithRevalidateTimeout bounds the background revalidations of stale-while-revalidate, it defaults to 30s
+func WithRevalidateTimeout(d time.Duration) CacheOption {
+	return func(c *Cache) {
+		c.revalidateTimeout = d
+	}
+}
+
+func NewCache(doer Doer, storage CacheStorage, opts ...CacheOption) *Cache {
+	c := &Cache{doer: doer, storage: storage, revalidateTimeout: 30 * time.Second, revalidating: map[string]bool{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Cache) Do(r *http.Request) (*http.Response, error) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		resp, err := c.doer.Do(r)
+		// unsafe methods invalidate the stored responses of the url
+		if err == nil && resp.StatusCode < 400 && r.Method != http.MethodOptions && r.Method != http.MethodTrace {
+			c.storage.Delete(cacheKey(http.MethodGet, r))
+			c.storage.Delete(cacheKey(http.MethodHead, r))
+		}
+		return resp, err
+	}
+
+	reqCC := parseCacheControl(r.Header)
+	if reqCC.has("no-store") || r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+		return c.doer.Do(r)
+	}
+
+	base := cacheKey(r.Method, r)
+	key, entry, ok := c.lookup(r, base)
+	if !ok || !entry.matches(r) {
+		return c.fetch(r, base, key, nil)
+	}
+
+	now := time.Now()
+	age, lifetime := entry.age(now), entry.lifetime()
+	respCC := parseCacheControl(entry.Header)
+	if reqCC.has("no-cache") || respCC.has("no-cache") {
+		return c.fetch(r, base, key, entry)
+	} else if age < lifetime {
+		return entry.response(r, age, CacheHit), nil
+	} else if swr, ok := respCC.seconds("stale-while-revalidate"); ok && age < lifetime+swr {
+		c.revalidate(r, base, key, entry)
+		return entry.response(r, age, CacheStale), nil
+	}
+	return c.fetch(r, base, key, entry)
+}
+
+// lookup returns the entry of r and the key it is stored at. Responses with a Vary header are
+// stored at a variant key holding the request values of the Vary headers, and the base key holds
+// a marker entry (without status) with their names. The marker is replaced when the url is
+// invalidated, which orphans the variants of the previous marker
+func (c *Cache) lookup(r *http.Request, base string) (string, *CacheEntry, bool) {
+	entry, ok := c.storage.Get(base)
+	if !ok || !entry.isVaryMarker() {
+		return base, entry, ok
+	}
+	key := variantKey(base, entry, r)
+	entry, ok = c.storage.Get(key)
+	return key, entry, ok
+}
+
+func (c *Cache) store(r *http.Request, base string, entry *CacheEntry) {
+	if len(entry.Vary) == 0 {
+		c.storage.Set(base, entry)
+		return
+	}
+	marker, ok := c.storage.Get(base)
+	if !ok || !marker.isVaryMarker() || !sameNames(marker.Vary, entry.Vary) {
+		marker = &CacheEntry{Vary: http.Header{}, StoredAt: time.Now()}
+		for name := range entry.Vary {
+			marker.Vary[name] = []string{}
+		}
+		c.storage.Set(base, marker)
+	}
+	c.storage.Set(variantKey(base, marker, r), entry)
+}
+
+func (e *CacheEntry) isVaryMarker() bool {
+	return e.StatusCode == 0
+}
+
+func variantKey(base string, marker *CacheEntry, r *http.Request) string {
+	names := make([]string, 0, len(marker.Vary))
+	for name := range marker.Vary {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(base)
+	sb.WriteString("\n")
+	sb.WriteString(strconv.FormatInt(marker.StoredAt.UnixNano(), 10))
+	for _, name := range names {
+		sb.WriteString("\n" + name + ": " + strings.Join(r.Header.Values(name), ","))
+	}
+	return sb.String()
+}
+
+func sameNames(a, b http.Header) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// fetch sends the request, conditional if entry has validators, and stores the response.
+// base is the key of the url, key the one of entry
+func (c *Cache) fetch(r *http.Request, base, key string, entry *CacheEntry) (*http.Response, error) {
+	req := r
+	if entry != nil {
+		etag, lastModified := entry.Header.Get("ETag"), entry.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			req = r.Clone(r.Context())
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				req.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	resp, err := c.doer.Do(req)
+	if entry != nil && entry.staleIfError(resp, err) {
+		if err == nil {
+			_ = drain(resp.Body)
+		}
+		return entry.response(r, entry.age(time.Now()), CacheStale), nil
+	} else if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		_ = drain(resp.Body)
+		updated := entry.revalidated(resp.Header, time.Now())
+		c.store(r, base, updated)
+		return updated.response(r, 0, CacheRevalidated), nil
+	} else if !storable(r, resp) {
+		if entry != nil {
+			c.storage.Delete(key) // the origin replaced the stored response
+		}
+		resp.Header.Set(CacheStatusHeader, CacheMiss)
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	entry = &CacheEntry{StatusCode: resp.StatusCode, Header: resp.Header, Body: body, StoredAt: time.Now(), Vary: varyHeaders(r, resp.Header)}
+	if initial, err := strconv.Atoi(resp.Header.Get("Age")); err == nil {
+		entry.StoredAt = entry.StoredAt.Add(-time.Duration(initial) * time.Second)
+	}
+	c.store(r, base, entry)
+	return entry.response(r, entry.age(time.Now()), CacheMiss), nil
+}
+
+// revalidate refreshes the entry in the background, once per key
+func (c *Cache) revalidate(r *http.Request, base, key string, entry *CacheEntry) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.revalidating[key] {
+		return
+	}
+	c.revalidating[key] = true
+
+	go func() {
+		defer func() {
+			c.mtx.Lock()
+			delete(c.revalidating, key)
+			c.mtx.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), c.revalidateTimeout)
+		defer cancel()
+		if resp, err := c.fetch(r.Clone(ctx), base, key, entry); err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+}
+
+func cacheKey(method string, r *http.Request) string {
+	return method + " " + r.URL.String()
+}
+
+func storable(r *http.Request, resp *http.Response) bool {
+	cc := parseCacheControl(resp.Header)
+	if resp.StatusCode != http.StatusOK || cc.has("no-store") || strings.TrimSpace(resp.Header.Get("Vary")) == "*" {
+		return false
+	} else if (r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "") && !cc.has("public") && !cc.has("s-maxage") {
+		return false // RFC 9111 section 3.5
+	}
+	entry := CacheEntry{Header: resp.Header}
+	return entry.lifetime() > 0 || resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+func varyHeaders(r *http.Request, header http.Header) http.Header {
+	vary := http.Header{}
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				vary.Set(name, r.Header.Get(name))
+			}
+		}
+	}
+	return vary
+}
+
+func (e *CacheEntry) matches(r *http.Request) bool {
+	for name := range e.Vary {
+		if e.Vary.Get(name) != r.Header.Get(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *CacheEntry) age(now time.Time) time.Duration {
+	return now.Sub(e.StoredAt)
+}
+
+// lifetime returns the freshness lifetime from max-age, Expires or the Last-Modified heuristic
+func (e *CacheEntry) lifetime() time.Duration {
+	if maxAge, ok := parseCacheControl(e.Header).seconds("max-age"); ok {
+		return maxAge
+	}
+	date, err := http.ParseTime(e.Header.Get("Date"))
+	if err != nil {
+		date = e.StoredAt
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		if t, err := http.ParseTime(expires); err == nil {
+			return t.Sub(date)
+		}
+		return 0 // invalid Expires means already expired
+	}
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil {
+		return date.Sub(lastModified) / 10
+	}
+	return 0
+}
+
+func (e *CacheEntry) staleIfError(resp *http.Response, err error) bool {
+	if err == nil {
+		switch resp.StatusCode {
+		case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		default:
+			return false
+		}
+	}
+	sie, ok := parseCacheControl(e.Header).seconds("stale-if-error")
+	return ok && e.age(time.Now()) < e.lifetime()+sie
+}
+
+// revalidated returns a copy of the entry updated with the headers of a 304 response
+func (e *CacheEntry) revalidated(header http.Header, now time.Time) *CacheEntry {
+	updated := *e
+	updated.Header = e.Header.Clone()
+	for k, v := range header {
+		if k != "Content-Length" {
+			updated.Header[k] = v
+		}
+	}
+	updated.StoredAt = now
+	return &updated
+}
+
+func (e *CacheEntry) response(r *http.Request, age time.Duration, status string) *http.Response {
+	header := e.Header.Clone()
+	header.Set(CacheStatusHeader, status)
+	if status != CacheMiss {
+		header.Set("Age", strconv.Itoa(int(age.Seconds())))
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       r,
+	}
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, v := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	if v, ok := cc[name]; ok {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			return time.Duration(n) * time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// MemoryCache is an in-memory LRU CacheStorage
+type MemoryCache struct {
+	mtx        sync.Mutex
+	maxEntries int
+	lru        *list.List // front is most recently used
+	entries    map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewMemoryCache keeps up to maxEntries entries, evicting the least recently used
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{maxEntries: maxEntries, lru: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (m *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if el, ok := m.entries[key]; ok {
+		m.lru.MoveToFront(el)
+		return el.Value.(*memoryCacheItem).entry, true
+	}
+	return nil, false
+}
+
+func (m *MemoryCache) Set(key string, entry *CacheEntry) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if el, ok := m.entries[key]; ok {
+		el.Value.(*memoryCacheItem).entry = entry
+		m.lru.MoveToFront(el)
+		return
+	}
+	m.entries[key] = m.lru.PushFront(&memoryCacheItem{key: key, entry: entry})
+	for m.maxEntries > 0 && m.lru.Len() > m.maxEntries {
+		oldest := m.lru.Back()
+		m.lru.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryCacheItem).key)
+	}
+}
+
+func (m *MemoryCache) Delete(key string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if el, ok := m.entries[key]; ok {
+		m.lru.Remove(el)
+		delete(m.entries, key)
+	}
+}
+
+// DiskCache is a CacheStorage keeping one gob encoded file per entry in a directory
+type DiskCache struct {
+	dir string
+}
+
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+// path hashes the key, it contains the unmasked url
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
+
+func (d *DiskCache) Get(key string) (*CacheEntry, bool) {
+	f, err := os.Open(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	var entry CacheEntry
+	if err := gob.NewDecoder(f).Decode(&entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+func (d *DiskCache) Set(key string, entry *CacheEntry) {
+	f, err := os.CreateTemp(d.dir, "tmp-")
+	if err != nil {
+		return
+	}
+	err = gob.NewEncoder(f).Encode(entry)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), d.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+}
+
+func (d *DiskCache) Delete(key string) {
+	_ = os.Remove(d.path(key))
+}
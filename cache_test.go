@@ -0,0 +1,285 @@
+package requests_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ajzo90/go-requests"
+	"github.com/matryer/is"
+)
+
+func TestCache(t *testing.T) {
+	var (
+		calls, conditional int32
+		failing            int32
+		cacheControl       = "max-age=0, stale-if-error=60"
+	)
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&conditional, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = io.WriteString(w, `{"v":1}`)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		cache := requests.NewCache(http.DefaultClient, requests.NewMemoryCache(10))
+		exec := func() (*requests.JSONResponse, error) {
+			return requests.NewGet(url).WithExtended(func(req *requests.ExtendedRequest) {
+				req.Doer(requests.CheckStatus(cache))
+			}).ExecJSON(context.Background())
+		}
+		expect := func(status string, calls, conditional int32) {
+			t.Helper()
+			resp, err := exec()
+			is.NoErr(err)
+			is.Equal(resp.Int("v"), 1)
+			is.Equal(resp.Header(requests.CacheStatusHeader), status)
+			is.Equal(atomic.LoadInt32(&calls), calls)
+			is.Equal(atomic.LoadInt32(&conditional), conditional)
+		}
+
+		expect(requests.CacheMiss, 1, 0)
+		expect(requests.CacheRevalidated, 2, 1) // the 304 comes out as 200
+
+		atomic.StoreInt32(&failing, 1)
+		expect(requests.CacheStale, 3, 1)
+		atomic.StoreInt32(&failing, 0)
+
+		cacheControl = "max-age=60"
+		expect(requests.CacheRevalidated, 4, 2)
+		expect(requests.CacheHit, 4, 2)
+
+		// unsafe methods invalidate
+		_, err := requests.NewPost(url).WithExtended(func(req *requests.ExtendedRequest) {
+			req.Doer(cache)
+		}).Extended().Do()
+		is.NoErr(err)
+		expect(requests.CacheMiss, 6, 2)
+	})
+}
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	var calls int32
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		w.Header().Set("Last-Modified", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+		_, _ = io.WriteString(w, `{"v":`+string('0'+n)+`}`)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		cache := requests.NewCache(http.DefaultClient, requests.NewMemoryCache(10))
+		get := func() *http.Response {
+			resp, err := cache.Do(newTestRequest(t, context.Background(), url))
+			is.NoErr(err)
+			return resp
+		}
+
+		is.True(!requests.FromCache(get()))
+		resp := get()
+		is.True(requests.FromCache(resp))
+		is.Equal(resp.Header.Get(requests.CacheStatusHeader), requests.CacheStale)
+		b, _ := io.ReadAll(resp.Body)
+		is.Equal(string(b), `{"v":1}`)
+
+		for atomic.LoadInt32(&calls) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		b, _ = io.ReadAll(get().Body)
+		is.Equal(string(b), `{"v":2}`)
+	})
+}
+
+func TestCacheStorage(t *testing.T) {
+	is := is.New(t)
+	disk, err := requests.NewDiskCache(t.TempDir())
+	is.NoErr(err)
+	memory := requests.NewMemoryCache(2)
+
+	for _, storage := range []requests.CacheStorage{memory, disk} {
+		entry := &requests.CacheEntry{StatusCode: 200, Header: http.Header{"Etag": []string{`"x"`}}, Body: []byte("body"), StoredAt: time.Now().Round(0)}
+		storage.Set("a", entry)
+		got, ok := storage.Get("a")
+		is.True(ok)
+		is.Equal(got.Body, entry.Body)
+		is.Equal(got.Header, entry.Header)
+		is.True(got.StoredAt.Equal(entry.StoredAt))
+		storage.Delete("a")
+		_, ok = storage.Get("a")
+		is.True(!ok)
+	}
+
+	memory.Set("a", &requests.CacheEntry{})
+	memory.Set("b", &requests.CacheEntry{})
+	_, _ = memory.Get("a")
+	memory.Set("c", &requests.CacheEntry{})
+	_, ok := memory.Get("b")
+	is.True(!ok) // least recently used
+	_, ok = memory.Get("a")
+	is.True(ok)
+}
+
+func TestCache_Authorization(t *testing.T) {
+	var cacheControl atomic.Value
+	cacheControl.Store("max-age=60")
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", cacheControl.Load().(string))
+		_, _ = io.WriteString(w, "data for "+r.Header.Get("Authorization"))
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		cache := requests.NewCache(http.DefaultClient, requests.NewMemoryCache(10))
+		get := func(token string) (string, string) {
+			req := newTestRequest(t, context.Background(), url)
+			req.Header.Set("Authorization", token)
+			resp, err := cache.Do(req)
+			is.NoErr(err)
+			defer resp.Body.Close()
+			b, _ := io.ReadAll(resp.Body)
+			return string(b), resp.Header.Get(requests.CacheStatusHeader)
+		}
+
+		body, status := get("alice")
+		is.Equal(body, "data for alice")
+		is.Equal(status, requests.CacheMiss)
+		body, status = get("bob")
+		is.Equal(body, "data for bob") // not stored
+		is.Equal(status, requests.CacheMiss)
+
+		cacheControl.Store("public, max-age=60")
+		_, status = get("alice")
+		is.Equal(status, requests.CacheMiss)
+		body, status = get("bob")
+		is.Equal(body, "data for alice") // public responses are shared
+		is.Equal(status, requests.CacheHit)
+	})
+}
+
+func TestCache_RevalidateTimeout(t *testing.T) {
+	var calls int32
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			<-r.Context().Done() // hangs until the revalidation gives up
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = io.WriteString(w, "ok")
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		cache := requests.NewCache(http.DefaultClient, requests.NewMemoryCache(10), requests.WithRevalidateTimeout(20*time.Millisecond))
+		get := func() string {
+			resp, err := cache.Do(newTestRequest(t, context.Background(), url))
+			is.NoErr(err)
+			_ = resp.Body.Close()
+			return resp.Header.Get(requests.CacheStatusHeader)
+		}
+
+		is.Equal(get(), requests.CacheMiss)
+		is.Equal(get(), requests.CacheStale)
+		for atomic.LoadInt32(&calls) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond)
+		is.Equal(get(), requests.CacheStale)
+		for start := time.Now(); atomic.LoadInt32(&calls) < 3 && time.Since(start) < time.Second; {
+			time.Sleep(time.Millisecond)
+		}
+		is.Equal(atomic.LoadInt32(&calls), int32(3)) // revalidated again after the timeout
+	})
+}
+
+func TestCache_Vary(t *testing.T) {
+	var calls int32
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept")
+		_, _ = io.WriteString(w, "as "+r.Header.Get("Accept"))
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		cache := requests.NewCache(http.DefaultClient, requests.NewMemoryCache(10))
+		get := func(accept string) (string, string) {
+			req := newTestRequest(t, context.Background(), url)
+			req.Header.Set("Accept", accept)
+			resp, err := cache.Do(req)
+			is.NoErr(err)
+			defer resp.Body.Close()
+			b, _ := io.ReadAll(resp.Body)
+			return string(b), resp.Header.Get(requests.CacheStatusHeader)
+		}
+
+		body, status := get("application/json")
+		is.Equal(body, "as application/json")
+		is.Equal(status, requests.CacheMiss)
+		body, status = get("text/csv")
+		is.Equal(body, "as text/csv")
+		is.Equal(status, requests.CacheMiss)
+
+		// the variants do not overwrite each other
+		body, status = get("application/json")
+		is.Equal(body, "as application/json")
+		is.Equal(status, requests.CacheHit)
+		body, status = get("text/csv")
+		is.Equal(body, "as text/csv")
+		is.Equal(status, requests.CacheHit)
+		is.Equal(atomic.LoadInt32(&calls), int32(2))
+
+		// unsafe methods invalidate all variants
+		post := newTestRequest(t, context.Background(), url)
+		post.Method = http.MethodPost
+		_, err := cache.Do(post)
+		is.NoErr(err)
+		_, status = get("text/csv")
+		is.Equal(status, requests.CacheMiss)
+	})
+}
+
+func TestCache_RevalidatedNoStore(t *testing.T) {
+	var cacheControl atomic.Value
+	cacheControl.Store("max-age=0, stale-while-revalidate=60")
+	var calls int32
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", cacheControl.Load().(string))
+		w.Header().Set("ETag", `"v`+string('0'+n)+`"`)
+		_, _ = io.WriteString(w, `{"v":`+string('0'+n)+`}`)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		cache := requests.NewCache(http.DefaultClient, requests.NewMemoryCache(10))
+		get := func() (string, string) {
+			resp, err := cache.Do(newTestRequest(t, context.Background(), url))
+			is.NoErr(err)
+			defer resp.Body.Close()
+			b, _ := io.ReadAll(resp.Body)
+			return string(b), resp.Header.Get(requests.CacheStatusHeader)
+		}
+
+		_, status := get()
+		is.Equal(status, requests.CacheMiss)
+		cacheControl.Store("no-store")
+		body, status := get()
+		is.Equal(body, `{"v":1}`)
+		is.Equal(status, requests.CacheStale)
+		for atomic.LoadInt32(&calls) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		// the origin stopped allowing storage, the old entry is not served again
+		body, status = get()
+		is.Equal(body, `{"v":3}`)
+		is.Equal(status, requests.CacheMiss)
+	})
+}
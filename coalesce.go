@@ -0,0 +1,122 @@
+package requests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Coalescer is a Doer sending identical concurrent GET and HEAD requests upstream once,
+// every waiter gets its own copy of the response. Requests are identical when the method,
+// the unmasked url and the Authorization, Cookie and selected headers match. Place it outside the Retryer so a
+// stampede shares one retried request:
+//
+//	doer := requests.NewCoalescer(requests.NewRetryer(http.DefaultClient, logger), requests.WithCoalesceHeaders("Accept"))
+type Coalescer struct {
+	doer    Doer
+	headers []string
+
+	mtx   sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done   chan struct{}
+	resp   *http.Response
+	body   []byte
+	err    error
+	ctxErr bool // the leader's context ended the call
+}
+
+type CoalescerOption func(*Coalescer)
+
+// WithCoalesceHeaders adds headers to the key, requests only coalesce if their values match.
+// Authorization and Cookie are always part of the key
+func WithCoalesceHeaders(names ...string) CoalescerOption {
+	return func(c *Coalescer) {
+		c.headers = append(c.headers, names...)
+	}
+}
+
+func NewCoalescer(doer Doer, opts ...CoalescerOption) *Coalescer {
+	c := &Coalescer{doer: doer, headers: []string{"Authorization", "Cookie"}, calls: map[string]*coalescedCall{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Coalescer) Do(r *http.Request) (*http.Response, error) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return c.doer.Do(r)
+	}
+
+	key := c.key(r)
+	c.mtx.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mtx.Unlock()
+		select {
+		case <-call.done:
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+		if call.ctxErr && r.Context().Err() == nil {
+			// the leader gave up, it says nothing about this request
+			return c.Do(r)
+		}
+		return call.response(r)
+	}
+	call := &coalescedCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mtx.Unlock()
+
+	c.do(r, call)
+
+	c.mtx.Lock()
+	delete(c.calls, key)
+	c.mtx.Unlock()
+	close(call.done)
+	return call.response(r)
+}
+
+func (c *Coalescer) do(r *http.Request, call *coalescedCall) {
+	call.resp, call.err = c.doer.Do(r)
+	if call.err == nil {
+		call.body, call.err = io.ReadAll(call.resp.Body)
+		_ = call.resp.Body.Close()
+	}
+	call.ctxErr = call.err != nil && r.Context().Err() != nil &&
+		(errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded))
+}
+
+func (c *Coalescer) key(r *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(r.Method)
+	sb.WriteString(" ")
+	sb.WriteString(r.URL.String())
+	for _, name := range c.headers {
+		sb.WriteString("\n")
+		sb.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return sb.String()
+}
+
+// response copies the shared response for r, the body is only set on success
+func (call *coalescedCall) response(r *http.Request) (*http.Response, error) {
+	if call.resp == nil {
+		return nil, call.err
+	}
+	resp := *call.resp
+	resp.Header = call.resp.Header.Clone()
+	resp.Request = r
+	resp.Body = http.NoBody
+	if call.err == nil {
+		resp.Body = io.NopCloser(bytes.NewReader(call.body))
+		resp.ContentLength = int64(len(call.body))
+	}
+	return &resp, call.err
+}
@@ -0,0 +1,150 @@
+package requests_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ajzo90/go-requests"
+	"github.com/matryer/is"
+)
+
+func TestCoalescer(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "hello "+r.Header.Get("Authorization"))
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		retryer := requests.NewRetryer(http.DefaultClient, logger, requests.WithBackoff(requests.ConstantBackoff(time.Millisecond)))
+		coalescer := requests.NewCoalescer(retryer)
+
+		const n = 10
+		var wg sync.WaitGroup
+		bodies := make([]string, n)
+		errs := make([]error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				req := newTestRequest(t, context.Background(), url)
+				req.Header.Set("Authorization", "a")
+				resp, err := coalescer.Do(req)
+				if errs[i] = err; err != nil {
+					return
+				}
+				defer resp.Body.Close()
+				b, _ := io.ReadAll(resp.Body)
+				bodies[i] = string(b)
+			}(i)
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		for i := 0; i < n; i++ {
+			is.NoErr(errs[i])
+			is.Equal(bodies[i], "hello a") // every waiter reads its own copy
+		}
+		is.Equal(atomic.LoadInt32(&calls), int32(2)) // one attempt and one retry
+
+		// other header values are separate requests
+		req := newTestRequest(t, context.Background(), url)
+		req.Header.Set("Authorization", "b")
+		resp, err := coalescer.Do(req)
+		is.NoErr(err)
+		b, _ := io.ReadAll(resp.Body)
+		is.Equal(string(b), "hello b")
+		is.Equal(atomic.LoadInt32(&calls), int32(3))
+	})
+}
+
+func TestCoalescer_LeaderCanceled(t *testing.T) {
+	var calls int32
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		coalescer := requests.NewCoalescer(http.DefaultClient)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		leader := make(chan error, 1)
+		go func() {
+			_, err := coalescer.Do(newTestRequest(t, ctx, url))
+			leader <- err
+		}()
+		for atomic.LoadInt32(&calls) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		waiter := make(chan string, 1)
+		go func() {
+			resp, err := coalescer.Do(newTestRequest(t, context.Background(), url))
+			if err != nil {
+				waiter <- err.Error()
+				return
+			}
+			b, _ := io.ReadAll(resp.Body)
+			waiter <- string(b)
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+
+		is.True(<-leader != nil)
+		is.Equal(<-waiter, "ok") // the waiter sends its own request
+	})
+}
+
+func TestCoalescer_Headers(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		_, _ = io.WriteString(w, "data for "+r.Header.Get("Authorization")+r.Header.Get("Cookie")+r.Header.Get("X-Tenant"))
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		coalescer := requests.NewCoalescer(http.DefaultClient, requests.WithCoalesceHeaders("X-Tenant"))
+
+		var wg sync.WaitGroup
+		headers := []string{"Authorization", "Authorization", "Cookie", "X-Tenant"}
+		values := []string{"alice", "bob", "carol", "dave"}
+		bodies := make([]string, len(values))
+		for i := range values {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				req := newTestRequest(t, context.Background(), url)
+				req.Header.Set(headers[i], values[i])
+				resp, err := coalescer.Do(req)
+				if err != nil {
+					return
+				}
+				defer resp.Body.Close()
+				b, _ := io.ReadAll(resp.Body)
+				bodies[i] = string(b)
+			}(i)
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		for i, v := range values {
+			is.Equal(bodies[i], "data for "+v)
+		}
+		is.Equal(atomic.LoadInt32(&calls), int32(len(values)))
+	})
+}
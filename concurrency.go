@@ -0,0 +1,133 @@
+package requests
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrQueueFull is returned by the ConcurrencyLimiter when the queue of a key is full
+var ErrQueueFull = errors.New("concurrency limiter queue is full")
+
+// ConcurrencyLimiter is a Doer capping the number of in-flight requests per key.
+// A request is in-flight until its body is closed
+type ConcurrencyLimiter struct {
+	doer     Doer
+	limit    int
+	maxQueue int
+	key      func(*http.Request) string
+
+	mtx       sync.Mutex
+	bulkheads map[string]*bulkhead
+}
+
+type bulkhead struct {
+	sem     chan struct{}
+	waiting int
+	users   int // in-flight and waiting, the bulkhead is evicted at zero
+}
+
+type ConcurrencyLimiterOption func(*ConcurrencyLimiter)
+
+// WithConcurrencyKey limits each key separately, e.g. WithConcurrencyKey(HostKey)
+func WithConcurrencyKey(key func(*http.Request) string) ConcurrencyLimiterOption {
+	return func(l *ConcurrencyLimiter) {
+		l.key = key
+	}
+}
+
+// WithMaxQueue fails fast with ErrQueueFull when n requests are already waiting for the key
+func WithMaxQueue(n int) ConcurrencyLimiterOption {
+	return func(l *ConcurrencyLimiter) {
+		l.maxQueue = n
+	}
+}
+
+// NewConcurrencyLimiter allows at most limit in-flight requests per key
+func NewConcurrencyLimiter(doer Doer, limit int, opts ...ConcurrencyLimiterOption) *ConcurrencyLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	l := &ConcurrencyLimiter{doer: doer, limit: limit, key: globalKey, bulkheads: map[string]*bulkhead{}}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+func (l *ConcurrencyLimiter) Do(request *http.Request) (*http.Response, error) {
+	key := l.key(request)
+	b, err := l.acquire(request, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			l.release(key, b)
+		})
+	}
+
+	resp, err := l.doer.Do(request)
+	if err != nil {
+		release()
+		return resp, err
+	}
+	resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: release}
+	return resp, nil
+}
+
+func (l *ConcurrencyLimiter) acquire(request *http.Request, key string) (*bulkhead, error) {
+	l.mtx.Lock()
+	b, ok := l.bulkheads[key]
+	if !ok {
+		b = &bulkhead{sem: make(chan struct{}, l.limit)}
+		l.bulkheads[key] = b
+	}
+
+	select {
+	case b.sem <- struct{}{}:
+		b.users++
+		l.mtx.Unlock()
+		return b, nil
+	default:
+	}
+
+	if l.maxQueue > 0 && b.waiting >= l.maxQueue {
+		l.mtx.Unlock()
+		return nil, ErrQueueFull
+	}
+	b.waiting++
+	b.users++
+	l.mtx.Unlock()
+
+	select {
+	case b.sem <- struct{}{}:
+		l.mtx.Lock()
+		b.waiting--
+		l.mtx.Unlock()
+		return b, nil
+	case <-request.Context().Done():
+		l.mtx.Lock()
+		b.waiting--
+		l.leave(key, b)
+		l.mtx.Unlock()
+		return nil, request.Context().Err()
+	}
+}
+
+func (l *ConcurrencyLimiter) release(key string, b *bulkhead) {
+	<-b.sem
+	l.mtx.Lock()
+	l.leave(key, b)
+	l.mtx.Unlock()
+}
+
+// leave must be called with l.mtx held
+func (l *ConcurrencyLimiter) leave(key string, b *bulkhead) {
+	b.users--
+	if b.users == 0 {
+		delete(l.bulkheads, key)
+	}
+}
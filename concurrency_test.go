@@ -0,0 +1,82 @@
+package requests_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ajzo90/go-requests"
+	"github.com/matryer/is"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	is := is.New(t)
+	var (
+		mtx               sync.Mutex
+		inFlight, maxSeen int
+	)
+	blockingDoer := requests.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		mtx.Lock()
+		inFlight++
+		if inFlight > maxSeen {
+			maxSeen = inFlight
+		}
+		mtx.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mtx.Lock()
+		inFlight--
+		mtx.Unlock()
+		return okDoer(r)
+	})
+	limiter := requests.NewConcurrencyLimiter(blockingDoer, 2, requests.WithConcurrencyKey(requests.HostKey))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(r *http.Request) {
+			defer wg.Done()
+			resp, err := limiter.Do(r)
+			if err == nil {
+				err = resp.Body.Close()
+			}
+			errs <- err
+		}(newTestRequest(t, context.Background(), "http://a.com"))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		is.NoErr(err)
+	}
+	is.Equal(maxSeen, 2)
+}
+
+func TestConcurrencyLimiter_Queue(t *testing.T) {
+	is := is.New(t)
+	limiter := requests.NewConcurrencyLimiter(okDoer, 1, requests.WithMaxQueue(1))
+
+	// the slot is held until the body is closed
+	held, err := limiter.Do(newTestRequest(t, context.Background(), "http://a.com"))
+	is.NoErr(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	queued := make(chan error)
+	go func() {
+		_, err := limiter.Do(newTestRequest(t, ctx, "http://a.com"))
+		queued <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = limiter.Do(newTestRequest(t, context.Background(), "http://a.com"))
+	is.Equal(err, requests.ErrQueueFull)
+	is.Equal(<-queued, context.DeadlineExceeded)
+
+	is.NoErr(held.Body.Close())
+	is.NoErr(held.Body.Close())
+	resp, err := limiter.Do(newTestRequest(t, context.Background(), "http://a.com"))
+	is.NoErr(err)
+	is.NoErr(resp.Body.Close())
+}
@@ -0,0 +1,75 @@
+package requests
+
+import (
+	"context"
+	"net/http"
+)
+
+// requestMeta is attached to the context of every *http.Request built by ExtendedRequest,
+// it lets doers further down the chain access builder state that is lost in the *http.Request
+type requestMeta struct {
+	statusValidator StatusValidator
+	maskedURL       func() string
+	idempotent      bool
+	route           string
+	sequentialBody  bool
+}
+
+type requestMetaKey struct{}
+
+func withRequestMeta(ctx context.Context, meta *requestMeta) context.Context {
+	return context.WithValue(ctx, requestMetaKey{}, meta)
+}
+
+func getRequestMeta(r *http.Request) *requestMeta {
+	if r != nil {
+		if meta, ok := r.Context().Value(requestMetaKey{}).(*requestMeta); ok {
+			return meta
+		}
+	}
+	return &requestMeta{}
+}
+
+// statusValidator returns the validator attached to the request, or DefaultStatusValidator
+func statusValidator(r *http.Request) StatusValidator {
+	if v := getRequestMeta(r).statusValidator; v != nil {
+		return v
+	}
+	return DefaultStatusValidator
+}
+
+// maskedURL returns the request url with all secrets masked
+func maskedURL(r *http.Request) string {
+	if f := getRequestMeta(r).maskedURL; f != nil {
+		return f()
+	} else if r != nil && r.URL != nil {
+		return r.URL.Redacted()
+	}
+	return ""
+}
+
+type attemptKey struct{}
+
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// Attempt returns the attempt number of a request sent by the Retryer, starting at 1
+func Attempt(r *http.Request) int {
+	if attempt, ok := r.Context().Value(attemptKey{}).(int); ok {
+		return attempt
+	}
+	return 1
+}
+
+type requestIDKey struct{}
+
+func withRequestID(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestID returns the RequestLogger id of a request sent by the Retryer, or 0
+func requestID(r *http.Request) int {
+	id, _ := r.Context().Value(requestIDKey{}).(int)
+	return id
+}
@@ -1,11 +1,11 @@
 package requests
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -62,6 +62,29 @@ func (req *ExtendedRequest) renderFn(masked bool) func(st stringer) string {
 	}
 }
 
+func (req *ExtendedRequest) setQuery(u *url.URL, render func(stringer) string) error {
+	if len(req.query) > 0 {
+		if u.RawQuery != "" {
+			return fmt.Errorf("raw query and query param not allowed")
+		}
+		q := u.Query()
+		for k, v := range req.query {
+			q.Add(k, render(v))
+		}
+		u.RawQuery = q.Encode()
+	}
+	return nil
+}
+
+func (req *ExtendedRequest) maskedURL() string {
+	u, err := url.Parse(req.fullUrl(req.renderFn(true)))
+	if err != nil {
+		return ""
+	}
+	_ = req.setQuery(u, req.renderFn(true))
+	return u.String()
+}
+
 // NewRequestContext builds a *http.Request
 func (req *ExtendedRequest) NewRequestContext(ctx context.Context, masked bool) (*http.Request, error) {
 	if req.method == nil {
@@ -71,30 +94,29 @@ func (req *ExtendedRequest) NewRequestContext(ctx context.Context, masked bool)
 		return nil, err
 	}
 	renderer := req.renderFn(masked)
+	ctx = withRequestMeta(ctx, &requestMeta{statusValidator: req.statusValidator, maskedURL: req.maskedURL, idempotent: req.idempotent, route: req.route, sequentialBody: req.sequentialBody})
 
-	var body io.Reader
-	if req.body != nil {
-		body = bytes.NewReader([]byte(renderer(req.body)))
-	}
-
-	request, err := http.NewRequestWithContext(ctx, renderer(req.method), req.fullUrl(renderer), body)
+	request, err := http.NewRequestWithContext(ctx, renderer(req.method), req.fullUrl(renderer), nil)
 	if err != nil {
 		return nil, err
 	}
+	if req.body != nil {
+		getBody, size, err := req.body(renderer, masked)
+		if err != nil {
+			return nil, err
+		}
+		setBody(request, getBody, size)
+	}
 
 	for k, v := range req.header {
 		request.Header.Add(k, renderer(v))
 	}
+	if req.idempotent && request.Header.Get(IdempotencyKeyHeader) == "" {
+		request.Header.Set(IdempotencyKeyHeader, newIdempotencyKey())
+	}
 
-	if len(req.query) > 0 {
-		if request.URL.RawQuery != "" {
-			return nil, fmt.Errorf("raw query and query param not allowed")
-		}
-		q := request.URL.Query()
-		for k, v := range req.query {
-			q.Add(k, renderer(v))
-		}
-		request.URL.RawQuery = q.Encode()
+	if err := req.setQuery(request.URL, renderer); err != nil {
+		return nil, err
 	}
 
 	return request, err
@@ -109,12 +131,22 @@ func (req *ExtendedRequest) Do(ctxs ...context.Context) (*http.Response, error)
 		ctx = context.Background()
 	}
 
-	if req.timeout != 0 {
-		var cancel func()
-		ctx, cancel = context.WithTimeout(ctx, req.timeout)
-		defer cancel()
+	if req.timeout == 0 {
+		return req.do(ctx)
 	}
 
+	// the timeout covers reading the body, cancel when the body is closed
+	ctx, cancel := context.WithTimeout(ctx, req.timeout)
+	resp, err := req.do(ctx)
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (req *ExtendedRequest) do(ctx context.Context) (*http.Response, error) {
 	request, err := req.NewRequestContext(ctx, false)
 	if err != nil {
 		return nil, err
@@ -122,11 +154,27 @@ func (req *ExtendedRequest) Do(ctxs ...context.Context) (*http.Response, error)
 	return req.doer.Do(request)
 }
 
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (c *cancelReadCloser) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
 func (req *ExtendedRequest) Doer(client Doer) *ExtendedRequest {
 	req.doer = client
 	return req
 }
 
+// Logger sets the logger used to report streamed bytes
+func (req *ExtendedRequest) Logger(logger RequestLogger) *ExtendedRequest {
+	req.logger = logger
+	return req
+}
+
 //// Reset the request
 //func (req *ExtendedRequest) Reset() {
 //	req.method = nil
@@ -148,8 +196,18 @@ func (req *ExtendedRequest) Clone() *Request {
 	newClient.err = req.err
 	newClient.doer = req.doer
 	newClient.timeout = req.timeout
+	newClient.logger = req.logger
+	newClient.statusValidator = req.statusValidator
+	newClient.idempotent = req.idempotent
+	newClient.route = req.route
+	newClient.sequentialBody = req.sequentialBody
 	req.header.CopyTo(newClient.header)
 	req.query.CopyTo(newClient.query)
 	req.secrets.CopyTo(newClient.secrets)
+	if req.form != nil {
+		newClient.form = stringerMap{}
+		req.form.CopyTo(newClient.form)
+		newClient.body = formBody(newClient.form)
+	}
 	return newClient
 }
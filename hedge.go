@@ -0,0 +1,192 @@
+package requests
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	hedgeSamples    = 200 // latencies kept to learn the hedge delay
+	minHedgeSamples = 20  // the initial delay is used until then
+)
+
+// Hedger is a Doer cutting tail latency by sending the request again when it has not
+// answered within a delay. The first successful response wins, the others are cancelled
+// and drained. The delay is fixed, or by default the p95 latency learned online.
+// Non-idempotent requests are sent once unless WithHedgeUnsafe is set, and so are multipart
+// bodies with io.ReadSeeker file parts that are not an io.ReaderAt, as the attempts share the offset
+//
+//	doer := requests.NewHedger(requests.CheckStatus(http.DefaultClient), requests.WithMaxHedges(2))
+type Hedger struct {
+	doer      Doer
+	delay     time.Duration
+	quantile  float64
+	maxHedges int
+	unsafe    bool
+
+	mtx       sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+type HedgerOption func(*Hedger)
+
+// WithHedgeDelay sends the hedges at a fixed delay
+func WithHedgeDelay(d time.Duration) HedgerOption {
+	return func(h *Hedger) {
+		h.delay = d
+		h.quantile = 0
+	}
+}
+
+// WithLearnedHedgeDelay sends the hedges at the quantile (e.g. 0.95) of the observed latencies,
+// initial is used until enough latencies are observed
+func WithLearnedHedgeDelay(quantile float64, initial time.Duration) HedgerOption {
+	return func(h *Hedger) {
+		h.delay = initial
+		h.quantile = quantile
+	}
+}
+
+// WithMaxHedges sets the number of extra attempts, it defaults to 1
+func WithMaxHedges(n int) HedgerOption {
+	return func(h *Hedger) {
+		h.maxHedges = n
+	}
+}
+
+// WithHedgeUnsafe hedges non-idempotent requests too
+func WithHedgeUnsafe() HedgerOption {
+	return func(h *Hedger) {
+		h.unsafe = true
+	}
+}
+
+func NewHedger(doer Doer, opts ...HedgerOption) *Hedger {
+	h := &Hedger{doer: doer, delay: 100 * time.Millisecond, quantile: 0.95, maxHedges: 1}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Delay returns the current hedge delay
+func (h *Hedger) Delay() time.Duration {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.quantile <= 0 || len(h.latencies) < minHedgeSamples {
+		return h.delay
+	}
+	sorted := append([]time.Duration(nil), h.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(h.quantile * float64(len(sorted)))
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func (h *Hedger) observe(d time.Duration) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if len(h.latencies) < hedgeSamples {
+		h.latencies = append(h.latencies, d)
+		return
+	}
+	h.latencies[h.next] = d
+	h.next = (h.next + 1) % hedgeSamples
+}
+
+type hedgeResult struct {
+	i      int
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+}
+
+func (h *Hedger) Do(r *http.Request) (*http.Response, error) {
+	if h.maxHedges < 1 || (!h.unsafe && !isIdempotent(r)) || getRequestMeta(r).sequentialBody ||
+		(r.Body != nil && r.Body != http.NoBody && r.GetBody == nil) {
+		return h.doer.Do(r)
+	}
+
+	results := make(chan hedgeResult, h.maxHedges+1)
+	cancels := make([]context.CancelFunc, 0, h.maxHedges+1)
+	send := func() {
+		i := len(cancels)
+		ctx, cancel := context.WithCancel(r.Context())
+		cancels = append(cancels, cancel)
+		req := r.Clone(ctx)
+		if i > 0 && r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				results <- hedgeResult{i: i, err: err, cancel: cancel}
+				return
+			}
+			req.Body = body
+		}
+		go func() {
+			resp, err := h.doer.Do(req)
+			results <- hedgeResult{i: i, resp: resp, err: err, cancel: cancel}
+		}()
+	}
+
+	start := time.Now()
+	send()
+	timer := time.NewTimer(h.Delay())
+	defer timer.Stop()
+
+	var (
+		inFlight = 1
+		last     hedgeResult
+	)
+	for inFlight > 0 {
+		select {
+		case <-timer.C:
+			if len(cancels) <= h.maxHedges {
+				send()
+				inFlight++
+				timer.Reset(h.Delay())
+			}
+			continue
+		case last = <-results:
+			inFlight--
+		}
+
+		if last.err != nil {
+			last.cancel()
+			// send the next hedge right away, unless the request itself is done
+			if inFlight == 0 && len(cancels) <= h.maxHedges && r.Context().Err() == nil {
+				send()
+				inFlight++
+			}
+			continue
+		}
+
+		// the latency of the request, a hedge that won was sent late
+		h.observe(time.Since(start))
+		for i, cancel := range cancels {
+			if i != last.i {
+				cancel()
+			}
+		}
+		go discardHedges(results, inFlight)
+		last.resp.Body = &cancelReadCloser{ReadCloser: last.resp.Body, cancel: last.cancel}
+		return last.resp, nil
+	}
+	return last.resp, last.err
+}
+
+// discardHedges drains the responses of the cancelled attempts
+func discardHedges(results <-chan hedgeResult, n int) {
+	for i := 0; i < n; i++ {
+		res := <-results
+		if res.err == nil {
+			_ = drain(res.resp.Body)
+		}
+		res.cancel()
+	}
+}
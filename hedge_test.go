@@ -0,0 +1,118 @@
+package requests_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ajzo90/go-requests"
+	"github.com/matryer/is"
+)
+
+func TestHedger(t *testing.T) {
+	var calls int32
+	canceled := make(chan struct{}, 1)
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1)%2 == 1 {
+			select {
+			case <-r.Context().Done():
+				canceled <- struct{}{}
+			case <-time.After(time.Second):
+			}
+			_, _ = io.WriteString(w, `"slow"`)
+			return
+		} else if len(body) > 0 {
+			_, _ = w.Write(body)
+			return
+		}
+		_, _ = io.WriteString(w, `"fast"`)
+	}, func(t *testing.T, url string) {
+		hedger := requests.NewHedger(requests.CheckStatus(http.DefaultClient), requests.WithHedgeDelay(10*time.Millisecond))
+		exec := func(req *requests.Request) (string, time.Duration) {
+			is := is.NewRelaxed(t)
+			start := time.Now()
+			resp, err := req.WithExtended(func(req *requests.ExtendedRequest) {
+				req.Doer(hedger)
+			}).ExecJSON()
+			is.NoErr(err)
+			return resp.String(), time.Since(start)
+		}
+		is := is.New(t)
+
+		body, elapsed := exec(requests.NewGet(url))
+		is.Equal(body, "fast")
+		is.True(elapsed < time.Second) // did not wait for the slow attempt
+		is.Equal(atomic.LoadInt32(&calls), int32(2))
+		<-canceled // the loser is cancelled
+
+		body, _ = exec(requests.NewPost(url).JSONBody("x"))
+		is.Equal(body, "slow") // not hedged
+		is.Equal(atomic.LoadInt32(&calls), int32(3))
+
+		atomic.StoreInt32(&calls, 0)
+		hedger = requests.NewHedger(requests.CheckStatus(http.DefaultClient), requests.WithHedgeDelay(10*time.Millisecond), requests.WithHedgeUnsafe())
+		body, _ = exec(requests.NewPost(url).JSONBody("x"))
+		is.Equal(body, "x") // the body is replayed
+		is.Equal(atomic.LoadInt32(&calls), int32(2))
+	})
+}
+
+func TestHedger_LearnedDelay(t *testing.T) {
+	withTestServer(t, echoHandler, func(t *testing.T, url string) {
+		is := is.New(t)
+		hedger := requests.NewHedger(http.DefaultClient, requests.WithLearnedHedgeDelay(0.95, time.Second))
+		is.Equal(hedger.Delay(), time.Second)
+		for i := 0; i < 30; i++ {
+			resp, err := hedger.Do(newTestRequest(t, context.Background(), url))
+			is.NoErr(err)
+			is.NoErr(resp.Body.Close())
+		}
+		is.True(hedger.Delay() < time.Second) // learned, no longer the initial delay
+	})
+}
+
+func TestHedger_LearnsRequestLatency(t *testing.T) {
+	var calls int32
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1)%2 == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(300 * time.Millisecond):
+			}
+		}
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		hedger := requests.NewHedger(http.DefaultClient, requests.WithLearnedHedgeDelay(0.5, 20*time.Millisecond))
+		for i := 0; i < 25; i++ {
+			resp, err := hedger.Do(newTestRequest(t, context.Background(), url))
+			is.NoErr(err)
+			is.NoErr(resp.Body.Close())
+		}
+		// every request is won by the hedge, the latencies include the delay before it was sent
+		is.True(hedger.Delay() >= 20*time.Millisecond)
+	})
+}
+
+func TestHedger_SeekableMultipart(t *testing.T) {
+	var calls int32
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = io.Copy(io.Discard, r.Body)
+		time.Sleep(30 * time.Millisecond)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		hedger := requests.NewHedger(http.DefaultClient, requests.WithHedgeDelay(time.Millisecond))
+		_, err := requests.New(url).Method(http.MethodPut).
+			MultipartBody(requests.File("file", "a.bin", struct{ io.ReadSeeker }{bytes.NewReader([]byte("abc"))})).
+			WithExtended(func(req *requests.ExtendedRequest) {
+				req.Doer(hedger)
+			}).Extended().Do()
+		is.NoErr(err)
+		is.Equal(atomic.LoadInt32(&calls), int32(1)) // the attempts would share the offset
+	})
+}
@@ -0,0 +1,55 @@
+package requests
+
+import (
+	"encoding/hex"
+	"net/http"
+)
+
+// IdempotencyKeyHeader is the header carrying the idempotency key of non-idempotent requests
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// Idempotent marks the request as safe to retry regardless of method.
+// A random Idempotency-Key header is generated once per execution and reused by all its attempts
+func (req *Request) Idempotent() *Request {
+	req.idempotent = true
+	return req
+}
+
+// IdempotencyKey marks the request as safe to retry and sets the Idempotency-Key header
+func (req *Request) IdempotencyKey(key interface{}) *Request {
+	return req.Idempotent().Header(IdempotencyKeyHeader, key)
+}
+
+// isIdempotent reports whether the Retryer may retry the request
+func isIdempotent(r *http.Request) bool {
+	return idempotentMethods[r.Method] || getRequestMeta(r).idempotent || r.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// newIdempotencyKey returns a random (version 4) UUID
+func newIdempotencyKey() string {
+	var b [16]byte
+	randomBytes(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
@@ -0,0 +1,15 @@
+package requests
+
+import "net/http"
+
+// HostKey keys requests by host
+func HostKey(r *http.Request) string {
+	return r.URL.Host
+}
+
+func globalKey(*http.Request) string {
+	return ""
+}
+
+// minPruneBuckets is the size at which maps of per key state start evicting idle keys
+const minPruneBuckets = 1024
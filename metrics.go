@@ -0,0 +1,225 @@
+package requests
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the default latency histogram buckets in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics records Prometheus style client metrics from the request lifecycle events,
+// and exposes them in the text exposition format
+//
+//	metrics := requests.NewMetrics()
+//	doer := requests.NewRetryer(http.DefaultClient, logger, requests.WithHooks(metrics.Hook()))
+//	http.Handle("/metrics", metrics.Handler())
+type Metrics struct {
+	mtx      sync.Mutex
+	duration *metricFamily
+	inFlight *metricFamily
+	attempts *metricFamily
+	retries  *metricFamily
+	bytes    *metricFamily
+}
+
+type MetricsOption func(*metricsOptions)
+
+type metricsOptions struct {
+	buckets []float64
+}
+
+// WithBuckets sets the latency histogram buckets in seconds
+func WithBuckets(buckets ...float64) MetricsOption {
+	return func(o *metricsOptions) {
+		o.buckets = buckets
+	}
+}
+
+func NewMetrics(opts ...MetricsOption) *Metrics {
+	o := metricsOptions{buckets: DefaultBuckets}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	buckets := append([]float64(nil), o.buckets...)
+	sort.Float64s(buckets)
+
+	return &Metrics{
+		duration: newMetricFamily("http_client_request_duration_seconds", "Latency of request attempts until the response headers are received.", "histogram", buckets, "method", "host", "route", "status_class"),
+		inFlight: newMetricFamily("http_client_requests_in_flight", "Request attempts waiting for the response headers.", "gauge", nil, "method", "host", "route"),
+		attempts: newMetricFamily("http_client_attempts_total", "Request attempts.", "counter", nil, "method", "host", "route", "status_class"),
+		retries:  newMetricFamily("http_client_retries_total", "Retried request attempts by reason.", "counter", nil, "method", "host", "route", "reason"),
+		bytes:    newMetricFamily("http_client_response_bytes_total", "Response body bytes read.", "counter", nil, "method", "host", "route", "status_class"),
+	}
+}
+
+// Hook returns the Hook recording the metrics, use it with WithHooks to get retry metrics
+func (m *Metrics) Hook() Hook {
+	return func(ev Event) {
+		host := ""
+		if u, err := url.Parse(ev.URL); err == nil {
+			host = u.Host
+		}
+		route := getRequestMeta(ev.Request).route
+
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+		switch ev.Type {
+		case BeforeAttempt:
+			m.inFlight.get(ev.Method, host, route).value++
+		case AfterAttempt:
+			m.inFlight.get(ev.Method, host, route).value--
+			m.attempts.get(ev.Method, host, route, statusClass(ev.Status)).value++
+			m.duration.observe(m.duration.get(ev.Method, host, route, statusClass(ev.Status)), ev.Duration.Seconds())
+		case Retry:
+			m.retries.get(ev.Method, host, route, retryReason(ev.Status)).value++
+		case BodyClosed:
+			m.bytes.get(ev.Method, host, route, statusClass(ev.Status)).value += float64(ev.Bytes)
+		}
+	}
+}
+
+// Middleware records the metrics of requests sent without a Retryer
+func (m *Metrics) Middleware() Middleware {
+	return Hooks(m.Hook())
+}
+
+// Handler serves the metrics in the Prometheus text exposition format
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = m.Write(w)
+	})
+}
+
+// Write writes the metrics in the Prometheus text exposition format
+func (m *Metrics) Write(w io.Writer) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, f := range []*metricFamily{m.duration, m.inFlight, m.attempts, m.retries, m.bytes} {
+		if err := f.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func statusClass(status int) string {
+	if status == 0 {
+		return "error"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+func retryReason(status int) string {
+	if status == 0 {
+		return "error"
+	}
+	return strconv.Itoa(status)
+}
+
+// Route sets the route template used as metrics label, e.g. "/users/{id}"
+func (req *Request) Route(template string) *Request {
+	req.route = template
+	return req
+}
+
+type metricFamily struct {
+	name, help, typ string
+	buckets         []float64
+	labels          []string
+	series          map[string]*metricSeries
+}
+
+type metricSeries struct {
+	labels []string
+	value  float64
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func newMetricFamily(name, help, typ string, buckets []float64, labels ...string) *metricFamily {
+	return &metricFamily{name: name, help: help, typ: typ, buckets: buckets, labels: labels, series: map[string]*metricSeries{}}
+}
+
+func (f *metricFamily) get(labels ...string) *metricSeries {
+	key := strings.Join(labels, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &metricSeries{labels: labels, counts: make([]uint64, len(f.buckets))}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *metricFamily) observe(s *metricSeries, v float64) {
+	if i := sort.SearchFloat64s(f.buckets, v); i < len(f.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (f *metricFamily) write(w io.Writer) error {
+	if len(f.series) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+		labels := f.formatLabels(s.labels)
+		if f.typ != "histogram" {
+			if _, err := fmt.Fprintf(w, "%s{%s} %s\n", f.name, labels, formatFloat(s.value)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var cumulative uint64
+		for i, upper := range f.buckets {
+			cumulative += s.counts[i]
+			if _, err := fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", f.name, labels, formatFloat(upper), cumulative); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n%s_sum{%s} %s\n%s_count{%s} %d\n",
+			f.name, labels, s.count, f.name, labels, formatFloat(s.sum), f.name, labels, s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *metricFamily) formatLabels(values []string) string {
+	pairs := make([]string, len(f.labels))
+	for i, name := range f.labels {
+		pairs[i] = name + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
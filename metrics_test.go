@@ -0,0 +1,74 @@
+package requests_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajzo90/go-requests"
+	"github.com/matryer/is"
+)
+
+func TestMetrics(t *testing.T) {
+	var attempt int
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempt++
+		if attempt < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.Copy(w, r.Body)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		metrics := requests.NewMetrics(requests.WithBuckets(60, 30))
+		retryer := requests.NewRetryer(http.DefaultClient, nil,
+			requests.WithBackoff(requests.ConstantBackoff(time.Millisecond)),
+			requests.WithHooks(metrics.Hook()),
+		)
+		_, err := requests.NewGet(url).Path("/users/42").Route("/users/{id}").JSONBody("hello").WithExtended(func(req *requests.ExtendedRequest) {
+			req.Doer(retryer)
+		}).ExecJSON()
+		is.NoErr(err)
+
+		rec := httptest.NewRecorder()
+		metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		is.Equal(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8")
+
+		labels := `method="GET",host="` + strings.TrimPrefix(url, "http://") + `",route="/users/{id}"`
+		var lines []string
+		for _, line := range strings.Split(rec.Body.String(), "\n") {
+			if strings.Contains(line, "_sum{") {
+				continue // timing dependent
+			}
+			lines = append(lines, strings.ReplaceAll(line, labels, "L"))
+		}
+		is.Equal(strings.Join(lines, "\n"), `# HELP http_client_request_duration_seconds Latency of request attempts until the response headers are received.
+# TYPE http_client_request_duration_seconds histogram
+http_client_request_duration_seconds_bucket{L,status_class="2xx",le="30"} 1
+http_client_request_duration_seconds_bucket{L,status_class="2xx",le="60"} 1
+http_client_request_duration_seconds_bucket{L,status_class="2xx",le="+Inf"} 1
+http_client_request_duration_seconds_count{L,status_class="2xx"} 1
+http_client_request_duration_seconds_bucket{L,status_class="5xx",le="30"} 1
+http_client_request_duration_seconds_bucket{L,status_class="5xx",le="60"} 1
+http_client_request_duration_seconds_bucket{L,status_class="5xx",le="+Inf"} 1
+http_client_request_duration_seconds_count{L,status_class="5xx"} 1
+# HELP http_client_requests_in_flight Request attempts waiting for the response headers.
+# TYPE http_client_requests_in_flight gauge
+http_client_requests_in_flight{L} 0
+# HELP http_client_attempts_total Request attempts.
+# TYPE http_client_attempts_total counter
+http_client_attempts_total{L,status_class="2xx"} 1
+http_client_attempts_total{L,status_class="5xx"} 1
+# HELP http_client_retries_total Retried request attempts by reason.
+# TYPE http_client_retries_total counter
+http_client_retries_total{L,reason="503"} 1
+# HELP http_client_response_bytes_total Response body bytes read.
+# TYPE http_client_response_bytes_total counter
+http_client_response_bytes_total{L,status_class="2xx"} 7
+http_client_response_bytes_total{L,status_class="5xx"} 0
+`)
+	})
+}
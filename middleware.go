@@ -0,0 +1,129 @@
+package requests
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// DoerFunc adapts a func to a Doer
+type DoerFunc func(r *http.Request) (*http.Response, error)
+
+func (f DoerFunc) Do(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// Middleware wraps a Doer
+type Middleware func(next Doer) Doer
+
+// Chain wraps doer with mw, the first middleware is the outermost
+func Chain(doer Doer, mw ...Middleware) Doer {
+	for i := len(mw) - 1; i >= 0; i-- {
+		doer = mw[i](doer)
+	}
+	return doer
+}
+
+type EventType int
+
+const (
+	// BeforeAttempt is emitted before an attempt is sent
+	BeforeAttempt EventType = iota
+	// AfterAttempt is emitted when the response headers are received or the attempt failed, Duration is the attempt latency
+	AfterAttempt
+	// Retry is emitted by the Retryer before it retries, Duration is the backoff delay and Err the reason
+	Retry
+	// BodyClosed is emitted when the response body is closed, Duration is measured from the start of the attempt
+	BodyClosed
+	// Done is emitted by the Retryer when it returns, Attempt is the number of attempts and Duration the total latency
+	Done
+)
+
+func (t EventType) String() string {
+	switch t {
+	case BeforeAttempt:
+		return "before_attempt"
+	case AfterAttempt:
+		return "after_attempt"
+	case Retry:
+		return "retry"
+	case BodyClosed:
+		return "body_closed"
+	case Done:
+		return "done"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a step in the lifecycle of a request
+type Event struct {
+	Type     EventType
+	ID       int // the RequestLogger id, set when sent by a Retryer
+	Request  *http.Request
+	Method   string
+	URL      string // masked
+	Attempt  int    // starts at 1
+	Status   int    // 0 if there is no response
+	Duration time.Duration
+	Bytes    int // body bytes read, set on BodyClosed
+	Err      error
+}
+
+// Hook receives request lifecycle events
+type Hook func(Event)
+
+// WithHooks emits the lifecycle events of every attempt, including Retry and Done events, to hooks
+func WithHooks(hooks ...Hook) RetryerOption {
+	return func(option *retryerOption) {
+		option.hooks = append(option.hooks, hooks...)
+	}
+}
+
+// Hooks is a Middleware emitting BeforeAttempt, AfterAttempt and BodyClosed events to hooks.
+// Use WithHooks to also receive Retry and Done events from a Retryer
+func Hooks(hooks ...Hook) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			ev := newEvent(BeforeAttempt, r)
+			emit(hooks, ev)
+
+			start := time.Now()
+			resp, err := next.Do(r)
+
+			ev.Type, ev.Duration, ev.Err = AfterAttempt, time.Since(start), err
+			ev.Status = responseStatus(resp, err)
+			emit(hooks, ev)
+
+			if err == nil && resp != nil {
+				resp.Body = &logReaderCloser{rc: resp.Body, logger: func(n int) {
+					ev.Type, ev.Duration, ev.Bytes, ev.Err = BodyClosed, time.Since(start), n, nil
+					emit(hooks, ev)
+				}}
+			}
+			return resp, err
+		})
+	}
+}
+
+func newEvent(typ EventType, r *http.Request) Event {
+	return Event{Type: typ, ID: requestID(r), Request: r, Method: r.Method, URL: maskedURL(r), Attempt: Attempt(r)}
+}
+
+func emit(hooks []Hook, ev Event) {
+	for _, hook := range hooks {
+		hook(ev)
+	}
+}
+
+// responseStatus returns the status of resp, or of the *StatusError
+func responseStatus(resp *http.Response, err error) int {
+	if resp != nil {
+		return resp.StatusCode
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package requests_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajzo90/go-requests"
+	"github.com/matryer/is"
+)
+
+func TestChain(t *testing.T) {
+	is := is.New(t)
+	var order []string
+	mw := func(name string) requests.Middleware {
+		return func(next requests.Doer) requests.Doer {
+			return requests.DoerFunc(func(r *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.Do(r)
+			})
+		}
+	}
+	_, err := requests.Chain(okDoer, mw("a"), mw("b"), mw("c")).Do(newTestRequest(t, context.Background(), "http://a.com"))
+	is.NoErr(err)
+	is.Equal(order, []string{"a", "b", "c"})
+}
+
+func TestHooks(t *testing.T) {
+	var attempt int
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempt++
+		if attempt < 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		io.Copy(w, r.Body)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		var events []string
+		hook := func(ev requests.Event) {
+			is.Equal(ev.URL, url+"/xxxxxx")
+			is.True(ev.Duration >= 0)
+			is.True(ev.ID > 0)
+			events = append(events, fmt.Sprintf("%s %d %d %d", ev.Type, ev.Attempt, ev.Status, ev.Bytes))
+		}
+		retryer := requests.NewRetryer(http.DefaultClient, logger,
+			requests.WithBackoff(requests.ConstantBackoff(time.Millisecond)),
+			requests.WithHooks(hook),
+		)
+		resp, err := requests.NewGet(url).Path("${key}").JSONBody("hello").WithExtended(func(req *requests.ExtendedRequest) {
+			req.Secret("key", "secret")
+			req.Doer(retryer)
+		}).ExecJSON()
+		is.NoErr(err)
+		is.Equal(resp.String(), "hello")
+		is.Equal(strings.Join(events, "\n"), strings.Join([]string{
+			"before_attempt 1 0 0",
+			"after_attempt 1 500 0",
+			"retry 1 500 0",
+			"body_closed 1 500 0",
+			"before_attempt 2 0 0",
+			"after_attempt 2 200 0",
+			"done 2 200 0",
+			"body_closed 2 200 7",
+		}, "\n"))
+	})
+}
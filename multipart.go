@@ -0,0 +1,295 @@
+package requests
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Part is a part of a multipart/form-data body, create it with Field or File
+type Part struct {
+	name     string
+	filename string
+	value    stringer // fields
+	src      interface{}
+	header   stringerMap
+	order    []string
+
+	start   int64 // section of seekable readers, measured by File
+	size    int64
+	err     error
+	mtx     sync.Mutex // held while a seeker without io.ReaderAt is read
+	claimed int32      // set when a one-shot reader is used
+}
+
+// Field creates a form field, value accepts the same lazy values as Header and Query
+func Field(name string, value interface{}) *Part {
+	return &Part{name: name, value: toStringer(value), header: stringerMap{}}
+}
+
+// File creates a file part streamed from src, a path or an io.Reader.
+// The section of an io.ReadSeeker from its current offset is sent, and sent again for retries
+// and later requests. Other readers can only be sent by one request, without retries
+func File(name, filename string, src interface{}) *Part {
+	p := &Part{name: name, filename: filename, src: src, header: stringerMap{}}
+	if rs, ok := src.(io.ReadSeeker); ok {
+		p.start, p.size, p.err = seekerSection(rs)
+	}
+	return p
+}
+
+// seekerSection returns the offset and remaining length of rs, and leaves the offset unchanged
+func seekerSection(rs io.ReadSeeker) (start, size int64, err error) {
+	if start, err = rs.Seek(0, io.SeekCurrent); err != nil {
+		return 0, 0, err
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, 0, err
+	}
+	_, err = rs.Seek(start, io.SeekStart)
+	return start, end - start, err
+}
+
+// Header sets a part header, e.g. the Content-Type of a file (application/octet-stream by default)
+func (p *Part) Header(key string, value interface{}) *Part {
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	if _, ok := p.header[key]; !ok {
+		p.order = append(p.order, key)
+	}
+	p.header[key] = toStringer(value)
+	return p
+}
+
+// MultipartBody sets a multipart/form-data body. File parts are streamed, they are not read into memory
+//
+//	requests.NewPost(url).MultipartBody(
+//		requests.Field("name", "report"),
+//		requests.File("file", "report.csv", "/tmp/report.csv").Header("Content-Type", "text/csv"),
+//	)
+func (req *Request) MultipartBody(parts ...*Part) *Request {
+	seen := map[*Part]bool{}
+	for _, p := range parts {
+		if err := p.validate(); err != nil {
+			req.setErr(err)
+		} else if seen[p] && p.src != nil {
+			req.setErr(fmt.Errorf("file part %q is added twice", p.name))
+		}
+		seen[p] = true
+	}
+	boundary := multipart.NewWriter(nil).Boundary()
+	req.replaceBody(multipartBody(boundary, parts))
+	for _, p := range parts {
+		_, seeker := p.src.(io.ReadSeeker)
+		if _, readerAt := p.src.(io.ReaderAt); seeker && !readerAt {
+			req.sequentialBody = true // the attempts can not read the part concurrently
+		}
+	}
+	return req.ContentType("multipart/form-data; boundary=" + boundary)
+}
+
+func (p *Part) validate() error {
+	if p.err != nil {
+		return fmt.Errorf("file part %q: %w", p.name, p.err)
+	}
+	for k, v := range p.header {
+		if v == nil {
+			return fmt.Errorf("can not convert header %s of part %q to stringer", k, p.name)
+		}
+	}
+	switch p.src.(type) {
+	case nil:
+		if p.value == nil {
+			return fmt.Errorf("can not convert part %q to stringer", p.name)
+		}
+	case string, io.Reader:
+	default:
+		return fmt.Errorf("file part %q: expected a path or io.Reader, got %T", p.name, p.src)
+	}
+	return nil
+}
+
+type renderedPart struct {
+	header textproto.MIMEHeader
+	size   int64
+	open   func() (io.ReadCloser, error)
+}
+
+func multipartBody(boundary string, parts []*Part) bodyFunc {
+	return func(render func(stringer) string, masked bool) (func() (io.ReadCloser, error), int64, error) {
+		rendered := make([]renderedPart, len(parts))
+		for i, p := range parts {
+			var err error
+			if rendered[i], err = p.render(render, masked); err != nil {
+				return nil, 0, err
+			}
+		}
+
+		size, err := multipartSize(boundary, rendered)
+		if err != nil {
+			return nil, 0, err
+		}
+		return func() (io.ReadCloser, error) {
+			return openMultipart(boundary, rendered)
+		}, size, nil
+	}
+}
+
+func (p *Part) render(render func(stringer) string, masked bool) (renderedPart, error) {
+	rp := renderedPart{header: textproto.MIMEHeader{}}
+	disposition := `form-data; name="` + quoteEscaper.Replace(p.name) + `"`
+	if p.value == nil {
+		disposition += `; filename="` + quoteEscaper.Replace(p.filename) + `"`
+		rp.header.Set("Content-Type", "application/octet-stream")
+	}
+	rp.header.Set("Content-Disposition", disposition)
+	for _, k := range p.order {
+		rp.header.Set(k, render(p.header[k]))
+	}
+
+	switch {
+	case p.value != nil:
+		rp.open, rp.size = stringReader(render(p.value))
+	case masked:
+		rp.open, rp.size = stringReader(maskedBinary)
+	default:
+		var err error
+		if rp.open, rp.size, err = p.file(); err != nil {
+			return rp, err
+		}
+	}
+	return rp, nil
+}
+
+func stringReader(s string) (func() (io.ReadCloser, error), int64) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(s)), nil
+	}, int64(len(s))
+}
+
+// file returns the opener and size of a file part, size is -1 if unknown.
+// It is called once per request, the returned opener once per attempt
+func (p *Part) file() (func() (io.ReadCloser, error), int64, error) {
+	switch src := p.src.(type) {
+	case string:
+		info, err := os.Stat(src)
+		if err != nil {
+			return nil, 0, err
+		}
+		return func() (io.ReadCloser, error) {
+			return os.Open(src)
+		}, info.Size(), nil
+	case io.ReaderAt:
+		if _, ok := src.(io.Seeker); ok {
+			// every attempt reads its own section, concurrent requests do not share an offset
+			return func() (io.ReadCloser, error) {
+				return io.NopCloser(io.NewSectionReader(src, p.start, p.size)), nil
+			}, p.size, nil
+		}
+	}
+
+	if src, ok := p.src.(io.ReadSeeker); ok {
+		// the attempts share the offset, one reads at a time
+		return func() (io.ReadCloser, error) {
+			p.mtx.Lock()
+			if _, err := src.Seek(p.start, io.SeekStart); err != nil {
+				p.mtx.Unlock()
+				return nil, err
+			}
+			return &unlockReadCloser{Reader: io.LimitReader(src, p.size), unlock: p.mtx.Unlock}, nil
+		}, p.size, nil
+	}
+
+	// one-shot readers fail when the request is built again, and when a retry needs them again
+	if !atomic.CompareAndSwapInt32(&p.claimed, 0, 1) {
+		return nil, 0, fmt.Errorf("file part %q: the reader was sent by a previous request", p.name)
+	}
+	var opened int32
+	return func() (io.ReadCloser, error) {
+		if !atomic.CompareAndSwapInt32(&opened, 0, 1) {
+			return nil, fmt.Errorf("file part %q: %w", p.name, errCanNotResetBody)
+		}
+		return io.NopCloser(p.src.(io.Reader)), nil
+	}, -1, nil
+}
+
+type unlockReadCloser struct {
+	io.Reader
+	once   sync.Once
+	unlock func()
+}
+
+func (u *unlockReadCloser) Close() error {
+	u.once.Do(u.unlock)
+	return nil
+}
+
+func multipartSize(boundary string, parts []renderedPart) (int64, error) {
+	var framing countWriter
+	mw := multipart.NewWriter(&framing)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return 0, err
+	}
+	size := int64(0)
+	for _, p := range parts {
+		if _, err := mw.CreatePart(p.header); err != nil {
+			return 0, err
+		} else if p.size < 0 {
+			return -1, nil
+		}
+		size += p.size
+	}
+	if err := mw.Close(); err != nil {
+		return 0, err
+	}
+	return size + int64(framing), nil
+}
+
+// openMultipart opens the parts and streams them through a pipe
+func openMultipart(boundary string, parts []renderedPart) (io.ReadCloser, error) {
+	contents := make([]io.ReadCloser, 0, len(parts))
+	closeAll := func() {
+		for _, c := range contents {
+			_ = c.Close()
+		}
+	}
+	for _, p := range parts {
+		rc, err := p.open()
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		contents = append(contents, rc)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer closeAll()
+		pw.CloseWithError(writeMultipart(pw, boundary, parts, contents))
+	}()
+	return pr, nil
+}
+
+func writeMultipart(w io.Writer, boundary string, parts []renderedPart, contents []io.ReadCloser) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+	for i, p := range parts {
+		pw, err := mw.CreatePart(p.header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(pw, contents[i]); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
@@ -0,0 +1,162 @@
+package requests_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ajzo90/go-requests"
+	"github.com/matryer/is"
+)
+
+func multipartHandler(t *testing.T, fail *int32) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(fail, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		if r.ContentLength != -1 && r.ContentLength != int64(len(b)) {
+			t.Errorf("content length %d, read %d", r.ContentLength, len(b))
+		}
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var sb strings.Builder
+		for _, k := range []string{"name", "token"} {
+			sb.WriteString(k + "=" + r.FormValue(k) + ";")
+		}
+		for _, k := range []string{"a", "b"} {
+			if f, h, err := r.FormFile(k); err == nil {
+				c, _ := io.ReadAll(f)
+				sb.WriteString(k + "=" + h.Filename + ":" + h.Header.Get("Content-Type") + ":" + string(c) + ";")
+			}
+		}
+		_, _ = io.WriteString(w, sb.String())
+	}
+}
+
+func TestMultipartBody(t *testing.T) {
+	var fail int32
+	withTestServer(t, multipartHandler(t, &fail), func(t *testing.T, url string) {
+		is := is.New(t)
+		path := filepath.Join(t.TempDir(), "a.csv")
+		is.NoErr(os.WriteFile(path, []byte("1,2,3"), 0o600))
+
+		name := "report"
+		newReq := func(b io.Reader) *requests.Request {
+			return requests.NewPost(url).Idempotent().MultipartBody(
+				requests.Field("name", &name),
+				requests.Field("token", "${token}"),
+				requests.File("a", "a.csv", path).Header("Content-Type", "text/csv"),
+				requests.File("b", "b.bin", b),
+			).WithExtended(func(req *requests.ExtendedRequest) {
+				req.Secret("token", "secret")
+				req.Doer(requests.NewRetryer(http.DefaultClient, logger, requests.WithBackoff(requests.ConstantBackoff(time.Millisecond))))
+			})
+		}
+		exec := func(req *requests.Request) (string, error) {
+			resp, err := req.Extended().Do()
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+			b, err := io.ReadAll(resp.Body)
+			return string(b), err
+		}
+
+		// seekable readers and files are rewound for the retries
+		atomic.StoreInt32(&fail, 2)
+		body, err := exec(newReq(bytes.NewReader([]byte("abc"))))
+		is.NoErr(err)
+		is.Equal(body, "name=report;token=secret;a=a.csv:text/csv:1,2,3;b=b.bin:application/octet-stream:abc;")
+
+		// other readers are streamed once
+		body, err = exec(newReq(io.MultiReader(strings.NewReader("abc"))))
+		is.NoErr(err)
+		is.Equal(body, "name=report;token=secret;a=a.csv:text/csv:1,2,3;b=b.bin:application/octet-stream:abc;")
+		atomic.StoreInt32(&fail, 1)
+		_, err = exec(newReq(io.MultiReader(strings.NewReader("abc"))))
+		is.True(strings.Contains(err.Error(), "can not reset body"))
+
+		var sb strings.Builder
+		is.NoErr(newReq(bytes.NewReader([]byte("abc"))).Extended().Write(&sb))
+		is.True(strings.Contains(sb.String(), "xxxxxx"))
+		is.True(strings.Contains(sb.String(), "[binary data]"))
+		is.True(!strings.Contains(sb.String(), "secret"))
+		is.True(!strings.Contains(sb.String(), "1,2,3"))
+
+		_, err = exec(requests.NewPost(url).MultipartBody(requests.File("a", "a", 1)))
+		is.True(err != nil)
+	})
+}
+
+func TestMultipartBody_Reuse(t *testing.T) {
+	var fail int32
+	withTestServer(t, multipartHandler(t, &fail), func(t *testing.T, url string) {
+		is := is.New(t)
+		path := filepath.Join(t.TempDir(), "a.csv")
+		is.NoErr(os.WriteFile(path, []byte("1,2,3"), 0o600))
+		exec := func(req *requests.Request) (string, error) {
+			resp, err := req.Extended().Do()
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+			b, err := io.ReadAll(resp.Body)
+			return string(b), err
+		}
+		const want = "name=;token=;a=a.csv:application/octet-stream:1,2,3;b=b.bin:application/octet-stream:abc;"
+
+		seekers := map[string]io.Reader{
+			"reader at": bytes.NewReader([]byte("abc")),
+			"seeker":    struct{ io.ReadSeeker }{strings.NewReader("abc")},
+		}
+		for name, b := range seekers {
+			t.Run(name, func(t *testing.T) {
+				is := is.New(t)
+				req := requests.NewPost(url).MultipartBody(requests.File("a", "a.csv", path), requests.File("b", "b.bin", b))
+
+				// sequential runs and clones send the whole section every time
+				for _, r := range []*requests.Request{req, req, req.Extended().Clone()} {
+					body, err := exec(r)
+					is.NoErr(err)
+					is.Equal(body, want)
+				}
+
+				// concurrent runs do not share the offset
+				var wg sync.WaitGroup
+				bodies := make([]string, 8)
+				for i := range bodies {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						bodies[i], _ = exec(req.Extended().Clone())
+					}(i)
+				}
+				wg.Wait()
+				for _, body := range bodies {
+					is.Equal(body, want)
+				}
+			})
+		}
+
+		// one-shot readers fail clearly when the builder runs again
+		req := requests.NewPost(url).MultipartBody(requests.File("b", "b.bin", io.MultiReader(strings.NewReader("abc"))))
+		body, err := exec(req)
+		is.NoErr(err)
+		is.Equal(body, "name=;token=;b=b.bin:application/octet-stream:abc;")
+		_, err = exec(req)
+		is.True(err != nil)
+		is.True(strings.Contains(err.Error(), "sent by a previous request"))
+	})
+}
@@ -0,0 +1,272 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fastjson"
+)
+
+// ErrMaxPages is returned by Paginator.Err when the max pages guard stopped the iteration
+var ErrMaxPages = errors.New("max pages reached")
+
+// PageStrategy binds the page state to the request and extracts the state of the next page from the response.
+// The state is an opaque token, the empty token is the first page
+type PageStrategy interface {
+	// Apply binds token to req, req is a clone of the base request
+	Apply(req *Request, token string)
+	// Next returns the token of the page after resp, and false if resp is the last page
+	Next(resp *JSONResponse, token string) (string, bool)
+}
+
+// Paginator iterates the pages of a paginated API
+//
+//	p := req.Paginate(requests.CursorPagination("cursor", "next_cursor"))
+//	for p.Next(ctx) {
+//		use(p.Page())
+//	}
+//	err := p.Err()
+type Paginator struct {
+	req      *Request
+	strategy PageStrategy
+	maxPages int
+
+	token string
+	pages int
+	done  bool
+	err   error
+	page  JSONResponse
+}
+
+type PaginatorOption func(*Paginator)
+
+// WithMaxPages stops the iteration with ErrMaxPages after n pages
+func WithMaxPages(n int) PaginatorOption {
+	return func(p *Paginator) {
+		p.maxPages = n
+	}
+}
+
+// WithResumeToken starts the iteration at the page given by a token from Paginator.Token
+func WithResumeToken(token string) PaginatorOption {
+	return func(p *Paginator) {
+		p.token = token
+	}
+}
+
+// Paginate returns a *Paginator iterating the pages of req
+func (req *Request) Paginate(strategy PageStrategy, opts ...PaginatorOption) *Paginator {
+	p := &Paginator{req: req, strategy: strategy}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Next fetches the next page, it returns false when there are no more pages or on error
+func (p *Paginator) Next(ctx context.Context) bool {
+	if p.done || p.err != nil {
+		return false
+	} else if p.maxPages > 0 && p.pages >= p.maxPages {
+		p.err = ErrMaxPages
+		return false
+	}
+
+	req := p.req.Extended().Clone()
+	p.strategy.Apply(req, p.token)
+	if err := req.Extended().ExecJSONPreAlloc(&p.page, ctx); err != nil {
+		p.err = err
+		return false
+	}
+	p.pages++
+
+	var more bool
+	if s, ok := p.strategy.(checkedPageStrategy); ok {
+		var err error
+		if p.token, more, err = s.nextPage(&p.page, p.token); err != nil {
+			p.err = err
+			return true // the page is valid, Next stops on the error
+		}
+	} else {
+		p.token, more = p.strategy.Next(&p.page, p.token)
+	}
+	p.done = !more
+	return true
+}
+
+// Page returns the current page, it is valid until the next call to Next
+func (p *Paginator) Page() *JSONResponse {
+	return &p.page
+}
+
+// Err returns the error that stopped the iteration
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+// Token returns the resume token of the next page, it is empty when all pages are fetched
+func (p *Paginator) Token() string {
+	if p.done {
+		return ""
+	}
+	return p.token
+}
+
+// checkedPageStrategy is a PageStrategy that fails when it can not find the next page state
+type checkedPageStrategy interface {
+	nextPage(resp *JSONResponse, token string) (string, bool, error)
+}
+
+type pageStrategy struct {
+	apply func(req *Request, token string)
+	next  func(resp *JSONResponse, token string) (string, bool, error)
+}
+
+func (s pageStrategy) Apply(req *Request, token string) {
+	s.apply(req, token)
+}
+
+func (s pageStrategy) Next(resp *JSONResponse, token string) (string, bool) {
+	next, more, err := s.next(resp, token)
+	return next, more && err == nil
+}
+
+func (s pageStrategy) nextPage(resp *JSONResponse, token string) (string, bool, error) {
+	return s.next(resp, token)
+}
+
+// CursorPagination sends the cursor found at keys in the response as the query param.
+// The cursor is a string or a number, a missing, null or empty cursor is the last page
+func CursorPagination(param string, keys ...string) PageStrategy {
+	return pageStrategy{
+		apply: func(req *Request, token string) {
+			if token != "" {
+				req.Query(param, token)
+			}
+		},
+		next: func(resp *JSONResponse, _ string) (string, bool, error) {
+			v := resp.Body().Get(keys...)
+			if v == nil {
+				return "", false, nil
+			}
+			switch v.Type() {
+			case fastjson.TypeNull:
+				return "", false, nil
+			case fastjson.TypeString:
+				cursor := string(v.GetStringBytes())
+				return cursor, cursor != "", nil
+			case fastjson.TypeNumber:
+				return v.String(), true, nil
+			default:
+				return "", false, fmt.Errorf("cursor at %q is a %s, expected a string or number", strings.Join(keys, "."), v.Type())
+			}
+		},
+	}
+}
+
+// OffsetPagination sends offset and limit query params, the last page is the one with less than limit items at itemKeys
+func OffsetPagination(offsetParam, limitParam string, limit int, itemKeys ...string) PageStrategy {
+	return pageStrategy{
+		apply: func(req *Request, token string) {
+			req.Query(offsetParam, strconv.Itoa(tokenInt(token, 0))).Query(limitParam, strconv.Itoa(limit))
+		},
+		next: func(resp *JSONResponse, token string) (string, bool, error) {
+			n := len(resp.GetArray(itemKeys...))
+			return strconv.Itoa(tokenInt(token, 0) + n), n >= limit && n > 0, nil
+		},
+	}
+}
+
+// PageNumberPagination sends the page number as the query param, starting at first. The last page is the one without items at itemKeys
+func PageNumberPagination(param string, first int, itemKeys ...string) PageStrategy {
+	return pageStrategy{
+		apply: func(req *Request, token string) {
+			req.Query(param, strconv.Itoa(tokenInt(token, first)))
+		},
+		next: func(resp *JSONResponse, token string) (string, bool, error) {
+			n := len(resp.GetArray(itemKeys...))
+			return strconv.Itoa(tokenInt(token, first) + 1), n > 0, nil
+		},
+	}
+}
+
+// LinkPagination follows the rel="next" url in the Link header (RFC 5988).
+// The query of the next url is merged over the query params of the request, params with
+// secrets keep their value so they stay masked. Repeated params keep their first value
+func LinkPagination() PageStrategy {
+	return pageStrategy{
+		apply: func(req *Request, token string) {
+			if token == "" {
+				return
+			}
+			u, err := url.Parse(token)
+			if err != nil {
+				req.setErr(err)
+				return
+			}
+			for k, v := range u.Query() {
+				if prev, ok := req.query[k]; !ok || !req.hasSecret(prev) {
+					req.query[k] = toStringer(v[0])
+				}
+			}
+			u.RawQuery = ""
+			req.Url(u.String()).Path("")
+		},
+		next: func(resp *JSONResponse, _ string) (string, bool, error) {
+			next := nextLink(resp.Header("Link"))
+			if next == "" {
+				return "", false, nil
+			} else if resp.URL() == nil {
+				return next, true, nil
+			} else if u, err := resp.URL().Parse(next); err == nil {
+				next = u.String() // resolve relative urls
+			}
+			return next, true, nil
+		},
+	}
+}
+
+// hasSecret reports whether s refers to a secret of req
+func (req *Request) hasSecret(s stringer) bool {
+	v := s.String()
+	for key := range req.secrets {
+		if strings.Contains(v, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func tokenInt(token string, def int) int {
+	if n, err := strconv.Atoi(token); err == nil {
+		return n
+	}
+	return def
+}
+
+// nextLink returns the url with rel="next" in a Link header, e.g. `<https://example.com?page=2>; rel="next"`
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		u := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(u, "<") || !strings.HasSuffix(u, ">") {
+			continue
+		}
+		for _, param := range parts[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(strings.TrimSpace(key), "rel") {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+				if strings.EqualFold(rel, "next") {
+					return u[1 : len(u)-1]
+				}
+			}
+		}
+	}
+	return ""
+}
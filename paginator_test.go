@@ -0,0 +1,146 @@
+package requests_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ajzo90/go-requests"
+	"github.com/matryer/is"
+)
+
+// pagesHandler serves the items 0..9
+func pagesHandler(w http.ResponseWriter, r *http.Request) {
+	const total = 10
+	q := r.URL.Query()
+	from, _ := strconv.Atoi(q.Get("offset"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit == 0 {
+		limit = 3
+	}
+	if page := q.Get("page"); page != "" {
+		p, _ := strconv.Atoi(page)
+		from = (p - 1) * limit
+	} else if cursor := q.Get("cursor"); cursor != "" {
+		from, _ = strconv.Atoi(cursor)
+	}
+
+	to := from + limit
+	if to > total {
+		to = total
+	}
+	if to < total {
+		w.Header().Set("Link", fmt.Sprintf(`</items?offset=%d&limit=%d>; rel="next", </items?offset=0>; rel="first"`, to, limit))
+	}
+	var items []int
+	for i := from; i < to; i++ {
+		items = append(items, i)
+	}
+	next := ""
+	if to < total {
+		next = strconv.Itoa(to)
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": items, "meta": map[string]string{"next": next}})
+}
+
+func collect(t *testing.T, p *requests.Paginator) []int {
+	t.Helper()
+	var items []int
+	for p.Next(context.Background()) {
+		for _, v := range p.Page().GetArray("items") {
+			items = append(items, v.GetInt())
+		}
+	}
+	return items
+}
+
+func TestPaginator(t *testing.T) {
+	withTestServer(t, pagesHandler, func(t *testing.T, url string) {
+		all := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+		strategies := map[string]requests.PageStrategy{
+			"cursor": requests.CursorPagination("cursor", "meta", "next"),
+			"offset": requests.OffsetPagination("offset", "limit", 3, "items"),
+			"page":   requests.PageNumberPagination("page", 1, "items"),
+			"link":   requests.LinkPagination(),
+		}
+		for name, strategy := range strategies {
+			t.Run(name, func(t *testing.T) {
+				is := is.New(t)
+				p := requests.NewGet(url).Path("/items").Paginate(strategy)
+				is.Equal(collect(t, p), all)
+				is.NoErr(p.Err())
+				is.Equal(p.Token(), "")
+
+				p = requests.NewGet(url).Path("/items").Paginate(strategy, requests.WithMaxPages(2))
+				is.Equal(collect(t, p), all[:6])
+				is.Equal(p.Err(), requests.ErrMaxPages)
+
+				p = requests.NewGet(url).Path("/items").Paginate(strategy, requests.WithResumeToken(p.Token()))
+				is.Equal(collect(t, p), all[6:])
+				is.NoErr(p.Err())
+			})
+		}
+	})
+}
+
+func TestCursorPagination_Types(t *testing.T) {
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			_, _ = fmt.Fprint(w, `{"items":[0],"next":2}`)
+		case "2":
+			_, _ = fmt.Fprint(w, `{"items":[2],"next":"x"}`)
+		case "x":
+			_, _ = fmt.Fprint(w, `{"items":[3],"next":null}`)
+		}
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		p := requests.NewGet(url).Paginate(requests.CursorPagination("cursor", "next"))
+		is.Equal(collect(t, p), []int{0, 2, 3}) // numeric cursors are followed
+		is.NoErr(p.Err())
+	})
+
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"items":[0],"next":{"id":1}}`)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		p := requests.NewGet(url).Paginate(requests.CursorPagination("cursor", "next"))
+		is.Equal(collect(t, p), []int{0})
+		is.True(p.Err() != nil) // never ends silently
+	})
+}
+
+func TestLinkPagination_Query(t *testing.T) {
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("api_key") != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		page, _ := strconv.Atoi(q.Get("page"))
+		if page < 2 {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next"`, page+1)) // without api_key
+		}
+		_, _ = fmt.Fprintf(w, `{"items":[%d]}`, page)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		req := requests.NewGet(url).Path("/items").Query("api_key", "${key}").Query("page", "0").
+			WithExtended(func(req *requests.ExtendedRequest) {
+				req.Secret("key", "s3cret")
+			})
+		p := req.Paginate(requests.LinkPagination())
+		is.Equal(collect(t, p), []int{0, 1, 2})
+		is.NoErr(p.Err())
+
+		// secrets carried by the link stay masked
+		next := req.Extended().Clone()
+		requests.LinkPagination().Apply(next, url+"/items?page=2&api_key=s3cret")
+		var sb strings.Builder
+		is.NoErr(next.Extended().Write(&sb))
+		is.True(strings.HasPrefix(sb.String(), "GET /items?api_key=xxxxxx&page=2 "))
+	})
+}
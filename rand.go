@@ -0,0 +1,10 @@
+package requests
+
+import "crypto/rand"
+
+// randomBytes fills b from crypto/rand, used for trace ids and idempotency keys
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,121 @@
+package requests
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a Doer enforcing a token bucket rate limit, globally or per key
+type RateLimiter struct {
+	doer  Doer
+	rate  float64 // tokens per second
+	burst float64
+	key   func(*http.Request) string
+	err   error
+
+	mtx     sync.Mutex
+	buckets map[string]*tokenBucket
+	pruneAt int
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type RateLimiterOption func(*RateLimiter)
+
+// WithRateLimitKey limits each key separately, e.g. WithRateLimitKey(HostKey)
+func WithRateLimitKey(key func(*http.Request) string) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.key = key
+	}
+}
+
+// NewRateLimiter allows perSecond requests per second, with bursts of up to burst requests.
+// A rate that is not positive and finite fails every request with an error
+func NewRateLimiter(doer Doer, perSecond float64, burst int, opts ...RateLimiterOption) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	l := &RateLimiter{doer: doer, rate: perSecond, burst: float64(burst), key: globalKey, buckets: map[string]*tokenBucket{}, pruneAt: minPruneBuckets}
+	if !(perSecond > 0) || math.IsInf(perSecond, 1) {
+		l.err = fmt.Errorf("invalid rate limit %v per second", perSecond)
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+func (l *RateLimiter) Do(request *http.Request) (*http.Response, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	key := l.key(request)
+	nextTry := l.reserve(key, time.Now())
+
+	if deadline, ok := request.Context().Deadline(); ok {
+		if deadline.Before(nextTry) {
+			l.release(key)
+			return nil, errDeadlineBeforeNext
+		}
+	}
+
+	if err := sleepUntil(request.Context(), nextTry); err != nil {
+		l.release(key)
+		return nil, err
+	}
+	return l.doer.Do(request)
+}
+
+// reserve takes a token from the bucket and returns when it is available
+func (l *RateLimiter) reserve(key string, now time.Time) time.Time {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		l.prune(now)
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	if now.After(b.last) {
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+	}
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return now
+	}
+	return now.Add(time.Duration(-b.tokens / l.rate * float64(time.Second)))
+}
+
+// release returns a reserved token that was not used
+func (l *RateLimiter) release(key string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(l.burst, b.tokens+1)
+	}
+}
+
+// prune evicts idle buckets, i.e. buckets that would be full by now
+func (l *RateLimiter) prune(now time.Time) {
+	if len(l.buckets) < l.pruneAt {
+		return
+	}
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.pruneAt = 2 * len(l.buckets)
+	if l.pruneAt < minPruneBuckets {
+		l.pruneAt = minPruneBuckets
+	}
+}
@@ -0,0 +1,78 @@
+package requests_test
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ajzo90/go-requests"
+	"github.com/matryer/is"
+)
+
+func TestRateLimiter(t *testing.T) {
+	is := is.New(t)
+	limiter := requests.NewRateLimiter(okDoer, 20, 2, requests.WithRateLimitKey(requests.HostKey))
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	errs := make(chan error, 12)
+	for i := 0; i < 6; i++ {
+		for _, url := range []string{"http://a.com", "http://b.com"} {
+			wg.Add(1)
+			go func(r *http.Request) {
+				defer wg.Done()
+				_, err := limiter.Do(r)
+				errs <- err
+			}(newTestRequest(t, context.Background(), url))
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		is.NoErr(err)
+	}
+
+	// 2 requests per host are served by the burst, the other 4 need 50ms each
+	elapsed := time.Since(start)
+	is.True(elapsed >= 190*time.Millisecond) // sleeps never end early
+	is.True(elapsed < 5*time.Second)
+}
+
+func TestRateLimiter_Deadline(t *testing.T) {
+	is := is.New(t)
+	limiter := requests.NewRateLimiter(okDoer, 1, 1)
+
+	_, err := limiter.Do(newTestRequest(t, context.Background(), "http://a.com"))
+	is.NoErr(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Do(newTestRequest(t, ctx, "http://a.com"))
+	is.True(err != nil)
+	is.Equal(err.Error(), "deadline is before next try")
+
+	_, err = requests.NewGet("http://a.com").
+		Timeout(100 * time.Millisecond).
+		WithExtended(func(req *requests.ExtendedRequest) {
+			req.Doer(requests.NewRetryer(limiter, logger))
+		}).
+		ExecJSON()
+	is.True(err != nil)
+	is.Equal(err.Error(), "deadline is before next try")
+}
+
+func TestRateLimiter_InvalidRate(t *testing.T) {
+	is := is.New(t)
+	for _, rate := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		start := time.Now()
+		limiter := requests.NewRateLimiter(okDoer, rate, 1)
+		for i := 0; i < 3; i++ {
+			_, err := limiter.Do(newTestRequest(t, context.Background(), "http://a.com"))
+			is.True(err != nil) // never unlimited
+		}
+		is.True(time.Since(start) < time.Second)
+	}
+}
@@ -3,14 +3,20 @@ package requests_test
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ajzo90/go-requests"
 	"github.com/matryer/is"
+	"github.com/valyala/fastjson"
 )
 
 type constStr string
@@ -100,6 +106,19 @@ func TestRequest_ExecJSON(t *testing.T) {
 	})
 }
 
+var okDoer = requests.DoerFunc(func(r *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Body: http.NoBody, Request: r}, nil
+})
+
+func newTestRequest(t *testing.T, ctx context.Context, url string) *http.Request {
+	t.Helper()
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
 func withTestServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request), fn func(t *testing.T, url string)) {
 	srv := httptest.NewServer(http.HandlerFunc(handler))
 	defer srv.Close()
@@ -141,3 +160,304 @@ func Test404Req(t *testing.T) {
 func errHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(404)
 }
+
+func TestValidateStatus(t *testing.T) {
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		echoHandler(w, r)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		req := requests.NewPost(url).JSONBody("created")
+
+		_, err := req.ExecJSON(context.Background())
+		is.True(err != nil)
+		is.Equal(err.Error(), "invalid status 201 Created")
+
+		for _, v := range []requests.StatusValidator{
+			requests.StatusCodes(http.StatusOK, http.StatusCreated),
+			requests.StatusRange(200, 201),
+			requests.StatusClass(2),
+		} {
+			resp, err := req.ValidateStatus(v).Extended().Clone().ExecJSON(context.Background())
+			is.NoErr(err)
+			is.Equal(resp.String(), "created")
+		}
+	})
+}
+
+func TestStatusError(t *testing.T) {
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Reason", "conflict")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(strings.Repeat("x", requests.MaxStatusErrorBody+10)))
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		for _, doer := range []requests.Doer{nil, doer} {
+			_, err := requests.NewPost(url).
+				Path("${key}").
+				Query("token", "${key}").
+				JSONBody("hello").
+				WithExtended(func(req *requests.ExtendedRequest) {
+					if doer != nil {
+						req.Doer(doer)
+					}
+					req.Secret("key", "secret")
+				}).
+				ExecJSON(context.Background())
+
+			var statusErr *requests.StatusError
+			is.True(errors.As(err, &statusErr))
+			is.Equal(statusErr.StatusCode, http.StatusConflict)
+			is.Equal(statusErr.Header.Get("X-Reason"), "conflict")
+			is.Equal(statusErr.Method, http.MethodPost)
+			is.Equal(statusErr.URL, url+"/xxxxxx?token=xxxxxx")
+			is.Equal(string(statusErr.Body), strings.Repeat("x", requests.MaxStatusErrorBody))
+			is.Equal(err.Error(), "invalid status 409 Conflict")
+		}
+	})
+}
+
+func TestExecInto(t *testing.T) {
+	type payload struct {
+		Foo string `json:"foo"`
+		Arr []int  `json:"arr"`
+	}
+	withTestServer(t, echoHandler, func(t *testing.T, url string) {
+		is := is.New(t)
+		in := payload{Foo: "bar", Arr: []int{1, 2}}
+
+		out, err := requests.ExecInto[payload](requests.NewPost(url).JSONBody(in), context.Background())
+		is.NoErr(err)
+		is.Equal(out.Foo, in.Foo)
+		is.Equal(out.Arr, in.Arr)
+
+		var resp requests.JSONResponse
+		for _, foo := range []string{"a long value to grow the buffer", "short"} {
+			var v payload
+			is.NoErr(requests.NewPost(url).JSONBody(payload{Foo: foo}).Extended().ExecJSONPreAlloc(&resp))
+			is.NoErr(resp.Decode(&v))
+			is.Equal(v.Foo, foo)
+			is.Equal(resp.String("foo"), foo)
+		}
+	})
+}
+
+func TestJSONResponse_Accessors(t *testing.T) {
+	withTestServer(t, echoHandler, func(t *testing.T, url string) {
+		is := is.New(t)
+		resp, err := requests.NewPost(url).JSONBody(map[string]interface{}{
+			"i":   -3,
+			"u":   uint64(1) << 60,
+			"f":   1.5,
+			"b":   true,
+			"ts":  "2022-03-04T05:06:07Z",
+			"obj": map[string]int{"a": 1, "b": 2},
+		}).ExecJSON(context.Background())
+		is.NoErr(err)
+
+		is.Equal(resp.Int64("i"), int64(-3))
+		is.Equal(resp.Uint64("u"), uint64(1)<<60)
+		is.Equal(resp.Float64("f"), 1.5)
+		is.Equal(resp.Bool("b"), true)
+		is.True(resp.Exists("obj", "a"))
+		is.True(!resp.Exists("obj", "c"))
+		is.Equal(resp.Time(time.RFC3339, "ts"), time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC))
+		is.True(resp.Time(time.RFC3339, "missing").IsZero())
+
+		sum := 0
+		resp.Object("obj").Visit(func(key []byte, v *fastjson.Value) {
+			sum += v.GetInt()
+		})
+		is.Equal(sum, 3)
+
+		strict := resp.Strict()
+		f, err := strict.Float64("f")
+		is.NoErr(err)
+		is.Equal(f, 1.5)
+
+		_, err = strict.String("obj", "c")
+		is.True(errors.Is(err, requests.ErrKeyNotFound))
+		is.Equal(err.Error(), "key not found: obj.c")
+
+		_, err = strict.Int("b")
+		is.True(err != nil)
+		is.True(!errors.Is(err, requests.ErrKeyNotFound))
+	})
+}
+
+func TestExecStream(t *testing.T) {
+	payload := strings.Repeat("0123456789", 100000)
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, payload)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		var logged []string
+		logger := requests.Logger(func(id int, err error, msg string) {
+			logged = append(logged, msg)
+		})
+		req := requests.NewGet(url).Timeout(time.Second).WithExtended(func(req *requests.ExtendedRequest) {
+			req.Logger(logger)
+		})
+
+		w := &bytes.Buffer{}
+		n, err := req.Extended().ExecTo(w, context.Background())
+		is.NoErr(err)
+		is.Equal(n, int64(len(payload)))
+		is.Equal(w.String(), payload)
+		is.Equal(logged, []string{"close 1000000"})
+
+		path := filepath.Join(t.TempDir(), "out")
+		n, err = req.Extended().ExecToFile(path)
+		is.NoErr(err)
+		is.Equal(n, int64(len(payload)))
+		b, err := os.ReadFile(path)
+		is.NoErr(err)
+		is.Equal(string(b), payload)
+
+		missing := filepath.Join(t.TempDir(), "missing")
+		_, err = req.Extended().Clone().Path("/missing").Extended().ExecToFile(missing)
+		var statusErr *requests.StatusError
+		is.True(errors.As(err, &statusErr))
+		_, err = os.Stat(missing)
+		is.True(os.IsNotExist(err))
+
+		// a failed download keeps the earlier file
+		_, err = req.Extended().Clone().Path("/missing").Extended().ExecToFile(path)
+		is.True(errors.As(err, &statusErr))
+		b, err = os.ReadFile(path)
+		is.NoErr(err)
+		is.Equal(string(b), payload)
+		entries, err := os.ReadDir(filepath.Dir(path))
+		is.NoErr(err)
+		is.Equal(len(entries), 1) // no temporary files left
+	})
+}
+
+func TestExecJSONLines(t *testing.T) {
+	long := strings.Repeat("x", 10000)
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"id":1}`+"\n\n"+`{"id":2,"s":"`+long+`"}`+"\r\n"+`{"id":3}`+"\n"+`{"id":`)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		var ids []int
+		err := requests.NewGet(url).ExecJSONLines(func(v *fastjson.Value) error {
+			ids = append(ids, v.GetInt("id"))
+			if v.GetInt("id") == 2 {
+				is.Equal(string(v.GetStringBytes("s")), long)
+			}
+			return nil
+		}, context.Background())
+		is.True(err != nil)
+		is.True(strings.HasPrefix(err.Error(), "line 5: "))
+		is.Equal(ids, []int{1, 2, 3})
+
+		ids = nil
+		err = requests.NewGet(url).ExecJSONLines(func(v *fastjson.Value) error {
+			ids = append(ids, v.GetInt("id"))
+			if len(ids) == 2 {
+				return requests.ErrStop
+			}
+			return nil
+		})
+		is.NoErr(err)
+		is.Equal(ids, []int{1, 2})
+
+		ids = nil
+		err = requests.NewGet(url).ExecJSONLines(func(v *fastjson.Value) error {
+			ids = append(ids, v.GetInt("id"))
+			return fmt.Errorf("enough: %w", requests.ErrStop)
+		})
+		is.NoErr(err) // wrapped ErrStop stops too
+		is.Equal(ids, []int{1})
+
+		errBoom := errors.New("boom")
+		err = requests.NewGet(url).ExecJSONLines(func(v *fastjson.Value) error {
+			return errBoom
+		})
+		is.True(errors.Is(err, errBoom))
+		is.Equal(err.Error(), "line 1: boom")
+	})
+}
+
+func TestFormBody(t *testing.T) {
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		_, _ = io.WriteString(w, r.Header.Get("Content-Type")+" "+r.PostForm.Encode())
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		scope := "read"
+		req := requests.NewPost(url).
+			FormBody("grant_type", "client_credentials").
+			FormValues(map[string]interface{}{"scope": &scope, "client_secret": "${secret}"}).
+			WithExtended(func(req *requests.ExtendedRequest) {
+				req.Secret("secret", "s3cr&t")
+			})
+		scope = "read write"
+
+		exec := func(req *requests.Request) string {
+			resp, err := req.Extended().Do()
+			is.NoErr(err)
+			defer resp.Body.Close()
+			b, _ := io.ReadAll(resp.Body)
+			return string(b)
+		}
+		is.Equal(exec(req), "application/x-www-form-urlencoded client_secret=s3cr%26t&grant_type=client_credentials&scope=read+write")
+
+		clone := req.Extended().Clone().FormBody("scope", "admin")
+		is.Equal(exec(clone), "application/x-www-form-urlencoded client_secret=s3cr%26t&grant_type=client_credentials&scope=admin")
+		is.Equal(exec(req), "application/x-www-form-urlencoded client_secret=s3cr%26t&grant_type=client_credentials&scope=read+write")
+
+		var sb strings.Builder
+		is.NoErr(req.Extended().Write(&sb))
+		is.True(strings.HasSuffix(sb.String(), "client_secret=xxxxxx&grant_type=client_credentials&scope=read+write"))
+	})
+}
+
+func TestBinaryBody(t *testing.T) {
+	var attempts int
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		_, _ = fmt.Fprintf(w, "%d %v %s", r.ContentLength, r.TransferEncoding, b)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		path := filepath.Join(t.TempDir(), "body.bin")
+		is.NoErr(os.WriteFile(path, []byte{0, 1, 'x'}, 0o600))
+
+		var opened int
+		retryer := requests.NewRetryer(http.DefaultClient, logger, requests.WithBackoff(requests.ConstantBackoff(time.Millisecond)))
+		exec := func(req *requests.Request) string {
+			attempts = 0
+			resp, err := req.Idempotent().WithExtended(func(req *requests.ExtendedRequest) {
+				req.Doer(retryer)
+			}).Extended().Do()
+			is.NoErr(err)
+			defer resp.Body.Close()
+			b, _ := io.ReadAll(resp.Body)
+			return string(b)
+		}
+
+		is.Equal(exec(requests.NewPost(url).BodyBytes([]byte("\x00\x01x"))), "3 [] \x00\x01x")
+		is.Equal(exec(requests.NewPost(url).BodyFile(path)), "3 [] \x00\x01x")
+		is.Equal(exec(requests.NewPost(url).BodyReader(func() (io.ReadCloser, error) {
+			opened++
+			return io.NopCloser(strings.NewReader("stream")), nil
+		})), "-1 [chunked] stream")
+		is.Equal(opened, 2) // replayed for the retry
+
+		var sb strings.Builder
+		is.NoErr(requests.NewPost(url).BodyFile(path).Extended().Write(&sb))
+		is.True(strings.Contains(sb.String(), "Content-Length: 13\r\n"))
+		is.True(strings.HasSuffix(sb.String(), "\r\n\r\n[binary data]"))
+
+		_, err := requests.NewPost(url).BodyFile(path + ".missing").Extended().Do()
+		is.True(errors.Is(err, os.ErrNotExist))
+	})
+}
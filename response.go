@@ -2,8 +2,10 @@ package requests
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/valyala/fastjson"
 )
@@ -46,6 +48,19 @@ func (req *Request) doJSON(ctxs ...context.Context) (*http.Response, error) {
 
 // ExecJSONPreAlloc executes the request and fill jsonResp
 func (req *ExtendedRequest) ExecJSONPreAlloc(jsonResp *JSONResponse, ctxs ...context.Context) error {
+	if err := req.readJSON(jsonResp, ctxs...); err != nil {
+		return err
+	}
+	if jsonResp.p == nil {
+		jsonResp.p = &fastjson.Parser{}
+	}
+	var err error
+	jsonResp.v, err = jsonResp.p.ParseBytes(jsonResp.buf)
+	return err
+}
+
+// readJSON executes the request and reads the body into jsonResp.buf, reusing its capacity
+func (req *ExtendedRequest) readJSON(jsonResp *JSONResponse, ctxs ...context.Context) error {
 	resp, err := req.doJSON(ctxs...)
 	if err != nil {
 		return err
@@ -53,7 +68,7 @@ func (req *ExtendedRequest) ExecJSONPreAlloc(jsonResp *JSONResponse, ctxs ...con
 	defer resp.Body.Close()
 
 	if resp.ContentLength == 0 {
-		// do nothing
+		jsonResp.buf = jsonResp.buf[:0]
 	} else if resp.ContentLength > 0 {
 		if cap(jsonResp.buf) >= int(resp.ContentLength) {
 			jsonResp.buf = jsonResp.buf[:resp.ContentLength]
@@ -70,18 +85,28 @@ func (req *ExtendedRequest) ExecJSONPreAlloc(jsonResp *JSONResponse, ctxs ...con
 	}
 
 	jsonResp.response.raw = resp
-	if jsonResp.p == nil {
-		jsonResp.p = &fastjson.Parser{}
-	}
-	jsonResp.v, err = jsonResp.p.ParseBytes(jsonResp.buf)
-
-	return err
+	return nil
 }
 
 type JSONParser interface {
 	ParseBytes([]byte) (*fastjson.Value, error)
 }
 
+// Decode unmarshals the JSON body into v using encoding/json
+func (r *JSONResponse) Decode(v interface{}) error {
+	return json.Unmarshal(r.buf, v)
+}
+
+// ExecInto executes the request and decodes the JSON body into a T
+func ExecInto[T any](req *Request, ctxs ...context.Context) (T, error) {
+	var v T
+	var r JSONResponse
+	if err := req.Extended().readJSON(&r, ctxs...); err != nil {
+		return v, err
+	}
+	return v, r.Decode(&v)
+}
+
 // ExecJSON executes the request and return a *JSONResponse
 func (req *Request) ExecJSON(ctxs ...context.Context) (*JSONResponse, error) {
 	var r JSONResponse
@@ -93,6 +118,14 @@ func (r *response) Header(key string) string {
 	return r.raw.Header.Get(key)
 }
 
+// URL returns the url of the request that produced the response, nil if unknown
+func (r *response) URL() *url.URL {
+	if r.raw == nil || r.raw.Request == nil {
+		return nil
+	}
+	return r.raw.Request.URL
+}
+
 type response struct {
 	raw *http.Response
 }
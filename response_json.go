@@ -0,0 +1,187 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/valyala/fastjson"
+)
+
+// ErrKeyNotFound is returned by the strict accessors when the keypath does not exist
+var ErrKeyNotFound = errors.New("key not found")
+
+// Int64 gets int64 from JSON body
+func (r *JSONResponse) Int64(keys ...string) int64 {
+	return r.v.GetInt64(keys...)
+}
+
+// Uint64 gets uint64 from JSON body
+func (r *JSONResponse) Uint64(keys ...string) uint64 {
+	return r.v.GetUint64(keys...)
+}
+
+// Float64 gets float64 from JSON body
+func (r *JSONResponse) Float64(keys ...string) float64 {
+	return r.v.GetFloat64(keys...)
+}
+
+// Bool gets bool from JSON body
+func (r *JSONResponse) Bool(keys ...string) bool {
+	return r.v.GetBool(keys...)
+}
+
+// Exists reports whether the keypath exists in the JSON body
+func (r *JSONResponse) Exists(keys ...string) bool {
+	return r.v.Exists(keys...)
+}
+
+// Time parses a string from JSON body with layout, the zero time is returned on failure
+func (r *JSONResponse) Time(layout string, keys ...string) time.Time {
+	t, _ := r.Strict().Time(layout, keys...)
+	return t
+}
+
+// Object gets object from JSON body, iterate the key/values with Visit
+func (r *JSONResponse) Object(keys ...string) *fastjson.Object {
+	return r.v.GetObject(keys...)
+}
+
+// Strict returns accessors that fail on missing keys and type mismatches instead of returning zero values
+func (r *JSONResponse) Strict() StrictJSON {
+	return StrictJSON{v: r.v}
+}
+
+// StrictJSON is holding the strict accessors of a JSON body
+type StrictJSON struct {
+	v *fastjson.Value
+}
+
+func (s StrictJSON) get(keys []string) (*fastjson.Value, error) {
+	if v := s.v.Get(keys...); v != nil {
+		return v, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, strings.Join(keys, "."))
+}
+
+func strictErr(keys []string, err error) error {
+	return fmt.Errorf("%s: %w", strings.Join(keys, "."), err)
+}
+
+// String gets string from JSON body
+func (s StrictJSON) String(keys ...string) (string, error) {
+	v, err := s.get(keys)
+	if err != nil {
+		return "", err
+	}
+	b, err := v.StringBytes()
+	if err != nil {
+		return "", strictErr(keys, err)
+	}
+	return string(b), nil
+}
+
+// Int gets int from JSON body
+func (s StrictJSON) Int(keys ...string) (int, error) {
+	v, err := s.get(keys)
+	if err != nil {
+		return 0, err
+	}
+	n, err := v.Int()
+	if err != nil {
+		return 0, strictErr(keys, err)
+	}
+	return n, nil
+}
+
+// Int64 gets int64 from JSON body
+func (s StrictJSON) Int64(keys ...string) (int64, error) {
+	v, err := s.get(keys)
+	if err != nil {
+		return 0, err
+	}
+	n, err := v.Int64()
+	if err != nil {
+		return 0, strictErr(keys, err)
+	}
+	return n, nil
+}
+
+// Uint64 gets uint64 from JSON body
+func (s StrictJSON) Uint64(keys ...string) (uint64, error) {
+	v, err := s.get(keys)
+	if err != nil {
+		return 0, err
+	}
+	n, err := v.Uint64()
+	if err != nil {
+		return 0, strictErr(keys, err)
+	}
+	return n, nil
+}
+
+// Float64 gets float64 from JSON body
+func (s StrictJSON) Float64(keys ...string) (float64, error) {
+	v, err := s.get(keys)
+	if err != nil {
+		return 0, err
+	}
+	f, err := v.Float64()
+	if err != nil {
+		return 0, strictErr(keys, err)
+	}
+	return f, nil
+}
+
+// Bool gets bool from JSON body
+func (s StrictJSON) Bool(keys ...string) (bool, error) {
+	v, err := s.get(keys)
+	if err != nil {
+		return false, err
+	}
+	b, err := v.Bool()
+	if err != nil {
+		return false, strictErr(keys, err)
+	}
+	return b, nil
+}
+
+// Time parses a string from JSON body with layout
+func (s StrictJSON) Time(layout string, keys ...string) (time.Time, error) {
+	str, err := s.String(keys...)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(layout, str)
+	if err != nil {
+		return time.Time{}, strictErr(keys, err)
+	}
+	return t, nil
+}
+
+// Object gets object from JSON body
+func (s StrictJSON) Object(keys ...string) (*fastjson.Object, error) {
+	v, err := s.get(keys)
+	if err != nil {
+		return nil, err
+	}
+	o, err := v.Object()
+	if err != nil {
+		return nil, strictErr(keys, err)
+	}
+	return o, nil
+}
+
+// Array gets array from JSON body
+func (s StrictJSON) Array(keys ...string) ([]*fastjson.Value, error) {
+	v, err := s.get(keys)
+	if err != nil {
+		return nil, err
+	}
+	a, err := v.Array()
+	if err != nil {
+		return nil, strictErr(keys, err)
+	}
+	return a, nil
+}
@@ -3,6 +3,7 @@ package requests
 import (
 	"context"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -19,25 +20,25 @@ type RetryPolicy func(*http.Response, error) (bool, error)
 
 type Retryer struct {
 	doer    Doer
-	nextTry time.Time
+	nextTry map[string]time.Time // shared gate per key, set by sharedBackoff
+	pruneAt int
+	key     func(*http.Request) string
 	mtx     sync.RWMutex
 
-	backoff       func() Backoffer
+	backoff       BackoffStrategy
 	sharedBackoff Backoffer
 	retryPolicy   RetryPolicy
+	maxAttempts   int
+	maxElapsed    time.Duration
+	budget        *RetryBudget
 
 	drainer func(io.ReadCloser) error
 	logger  RequestLogger
+	hooks   []Hook
 }
 
-var backoff = func() Backoffer {
-	min := time.Second / 10
-	var attempts int
-	return Backoff(func(resp *http.Response) time.Duration {
-		attempts++
-		return time.Duration(math.Pow(2, float64(attempts))) * min
-	})
-}
+// backoff is the default BackoffStrategy, doubling from 200ms up to 30s with equal jitter
+var backoff = ExponentialBackoff(time.Second/5, 30*time.Second, EqualJitter)
 
 type RequestLogger interface {
 	NextID() int
@@ -45,7 +46,14 @@ type RequestLogger interface {
 }
 
 type retryerOption struct {
-	retryPolicy RetryPolicy
+	retryPolicy   RetryPolicy
+	backoff       BackoffStrategy
+	sharedBackoff Backoffer
+	key           func(*http.Request) string
+	hooks         []Hook
+	maxAttempts   int
+	maxElapsed    time.Duration
+	budget        *RetryBudget
 }
 
 type RetryerOption func(*retryerOption)
@@ -56,37 +64,137 @@ func WithRetryPolicy(policy RetryPolicy) RetryerOption {
 	}
 }
 
-func NewRetryer(doer Doer, logger RequestLogger, opts ...RetryerOption) Doer {
+// WithSharedBackoff sets the backoff shared by all requests of the retryer, it defaults to DefaultSharedBackoff
+func WithSharedBackoff(b Backoffer) RetryerOption {
+	return func(option *retryerOption) {
+		option.sharedBackoff = b
+	}
+}
+
+// WithMaxAttempts gives up after n attempts, including the first one
+func WithMaxAttempts(n int) RetryerOption {
+	return func(option *retryerOption) {
+		option.maxAttempts = n
+	}
+}
+
+// WithMaxElapsed gives up when the next retry would start later than d after the first attempt
+func WithMaxElapsed(d time.Duration) RetryerOption {
+	return func(option *retryerOption) {
+		option.maxElapsed = d
+	}
+}
+
+// WithRetryBudget gives up when the budget, which may be shared between retryers, is exhausted
+func WithRetryBudget(budget *RetryBudget) RetryerOption {
+	return func(option *retryerOption) {
+		option.budget = budget
+	}
+}
+
+// WithSharedBackoffKey sets the key of the shared backoff gate, it defaults to HostKey
+func WithSharedBackoffKey(key func(*http.Request) string) RetryerOption {
+	return func(option *retryerOption) {
+		option.key = key
+	}
+}
+
+// NewRetryer retries requests to doer according to the options, logger may be nil
+func NewRetryer(doer Doer, logger RequestLogger, opts ...RetryerOption) *Retryer {
+	if logger == nil {
+		logger = Logger(func(id int, err error, msg string) {})
+	}
 	o := retryerOption{
-		retryPolicy: DefaultRetryPolicy,
+		retryPolicy:   DefaultRetryPolicy,
+		backoff:       backoff,
+		sharedBackoff: DefaultSharedBackoff,
+		key:           HostKey,
 	}
 	for _, opt := range opts {
 		opt(&o)
 	}
-	return &Retryer{doer: doer, backoff: backoff, sharedBackoff: DefaultSharedBackoff, retryPolicy: o.retryPolicy, drainer: drain, logger: logger}
+	if len(o.hooks) > 0 {
+		doer = Hooks(o.hooks...)(doer)
+	}
+	return &Retryer{
+		doer:          doer,
+		backoff:       o.backoff,
+		sharedBackoff: o.sharedBackoff,
+		nextTry:       map[string]time.Time{},
+		pruneAt:       minPruneBuckets,
+		key:           o.key,
+		retryPolicy:   o.retryPolicy,
+		maxAttempts:   o.maxAttempts,
+		maxElapsed:    o.maxElapsed,
+		budget:        o.budget,
+		drainer:       drain,
+		logger:        logger,
+		hooks:         o.hooks,
+	}
 }
 
 type Backoffer interface {
 	Next(resp *http.Response) time.Duration
 }
 
-func (r *Retryer) retryAfter() time.Time {
+func (r *Retryer) retryAfter(key string) time.Time {
 	r.mtx.RLock()
 	defer r.mtx.RUnlock()
-	return r.nextTry
+	return r.nextTry[key]
 }
 
-func (r *Retryer) updateRetryAfter(retryAfter time.Duration) {
+func (r *Retryer) updateRetryAfter(key string, retryAfter time.Duration) {
 	if retryAfter > 0 {
-		newTs := time.Now().Add(retryAfter)
+		now := time.Now()
+		newTs := now.Add(retryAfter)
 		r.mtx.Lock()
-		if newTs.After(r.nextTry) {
-			r.nextTry = newTs
+		if newTs.After(r.nextTry[key]) {
+			r.prune(now)
+			r.nextTry[key] = newTs
 		}
 		r.mtx.Unlock()
 	}
 }
 
+// prune evicts passed gates, it must be called with r.mtx held
+func (r *Retryer) prune(now time.Time) {
+	if len(r.nextTry) < r.pruneAt {
+		return
+	}
+	for key, ts := range r.nextTry {
+		if ts.Before(now) {
+			delete(r.nextTry, key)
+		}
+	}
+	r.pruneAt = 2 * len(r.nextTry)
+	if r.pruneAt < minPruneBuckets {
+		r.pruneAt = minPruneBuckets
+	}
+}
+
+// NextTry returns the time before which requests for key are held back by the shared backoff,
+// the zero time if they are not
+func (r *Retryer) NextTry(key string) time.Time {
+	if ts := r.retryAfter(key); ts.After(time.Now()) {
+		return ts
+	}
+	return time.Time{}
+}
+
+// NextTries returns a snapshot of the keys currently held back by the shared backoff
+func (r *Retryer) NextTries() map[string]time.Time {
+	now := time.Now()
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	gates := map[string]time.Time{}
+	for key, ts := range r.nextTry {
+		if ts.After(now) {
+			gates[key] = ts
+		}
+	}
+	return gates
+}
+
 var (
 	errCanNotResetBody    = fmt.Errorf("can not reset body")
 	errDeadlineBeforeNext = fmt.Errorf("deadline is before next try")
@@ -113,17 +221,31 @@ func (d *defaultLogger) Log(id int, err error, msg string) {
 	d.logger(id, err, msg)
 }
 
-func (r *Retryer) Do(request *http.Request) (_ *http.Response, err error) {
-	var nextTry time.Time
+func (r *Retryer) Do(request *http.Request) (res *http.Response, err error) {
+	var (
+		nextTry  time.Time
+		attempts int
+		start    = time.Now()
+	)
 
 	id := r.logger.NextID()
 	defer func() {
 		r.logger.Log(id, err, "done")
+		if len(r.hooks) > 0 {
+			ev := newEvent(Done, request)
+			ev.ID, ev.Attempt, ev.Status, ev.Duration, ev.Err = id, attempts, responseStatus(res, err), time.Since(start), err
+			emit(r.hooks, ev)
+		}
 	}()
 
+	if r.budget != nil {
+		r.budget.deposit()
+	}
+
+	key := r.key(request)
 	backoff := r.backoff()
-	for attempts := 0; ; attempts++ {
-		if retryAfter := r.retryAfter(); retryAfter.After(nextTry) {
+	for {
+		if retryAfter := r.retryAfter(key); retryAfter.After(nextTry) {
 			nextTry = retryAfter
 		}
 
@@ -145,40 +267,136 @@ func (r *Retryer) Do(request *http.Request) (_ *http.Response, err error) {
 			return nil, err
 		}
 
-		if request.Method != http.MethodGet {
-			request.Body, err = request.GetBody()
+		attempts++
+		attempt := request.WithContext(withAttempt(withRequestID(request.Context(), id), attempts))
+		if attempt.GetBody != nil {
+			attempt.Body, err = attempt.GetBody()
 			if err != nil {
 				return nil, err
-			} else if request.Body == nil {
+			} else if attempt.Body == nil {
 				return nil, errCanNotResetBody
 			}
+		} else if attempts > 1 && attempt.Body != nil && attempt.Body != http.NoBody {
+			return nil, errCanNotResetBody // the body was read by the previous attempt
 		}
 
-		resp, err := r.doer.Do(request)
+		resp, err := r.doer.Do(attempt)
 		if err == nil {
 			resp.Body = &logReaderCloser{rc: resp.Body, logger: func(n int) {
 				r.logger.Log(id, nil, fmt.Sprintf("close %d", n))
 			}}
-			r.updateRetryAfter(r.sharedBackoff.Next(resp))
+			r.updateRetryAfter(key, r.sharedBackoff.Next(resp))
 		}
 		retry, retryErr := r.retryPolicy(resp, err)
+		if retry && !isIdempotent(request) {
+			retry = false
+			if retryErr == nil && resp != nil {
+				retryErr = newStatusError(resp)
+			}
+		}
 		if !retry {
 			if err == nil && retryErr != nil {
 				_ = r.drainer(resp.Body)
 			}
 			return resp, retryErr
 		}
+
+		delay := backoff.Next(resp)
+		if reason := r.giveUp(attempts, time.Since(start)+delay); reason != nil {
+			if retryErr == nil && resp != nil {
+				retryErr = newStatusError(resp)
+			}
+			if resp != nil {
+				_ = r.drainer(resp.Body)
+			}
+			return nil, &RetryError{Reason: reason, Attempts: attempts, Err: retryErr}
+		}
+
+		if len(r.hooks) > 0 {
+			ev := newEvent(Retry, attempt)
+			ev.Status, ev.Duration, ev.Err = responseStatus(resp, retryErr), delay, retryErr
+			emit(r.hooks, ev)
+		}
 		if resp != nil {
 			r.logger.Log(id, retryErr, fmt.Sprintf("retry: %s", resp.Status))
 			_ = r.drainer(resp.Body)
 		}
 
-		nextTry = time.Now().Add(backoff.Next(resp))
+		nextTry = time.Now().Add(delay)
+	}
+}
+
+// giveUp returns the reason to not retry, or nil
+func (r *Retryer) giveUp(attempts int, elapsed time.Duration) error {
+	if r.maxAttempts > 0 && attempts >= r.maxAttempts {
+		return ErrMaxAttempts
+	} else if r.maxElapsed > 0 && elapsed > r.maxElapsed {
+		return ErrMaxElapsed
+	} else if r.budget != nil && !r.budget.withdraw() {
+		return ErrRetryBudget
+	}
+	return nil
+}
+
+var (
+	ErrMaxAttempts = errors.New("max attempts reached")
+	ErrMaxElapsed  = errors.New("max elapsed time reached")
+	ErrRetryBudget = errors.New("retry budget exhausted")
+)
+
+// RetryError is returned when the Retryer gives up before the retry policy does.
+// It matches its Reason and the last error with errors.Is/errors.As
+type RetryError struct {
+	Reason   error // ErrMaxAttempts, ErrMaxElapsed or ErrRetryBudget
+	Attempts int
+	Err      error // the last error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%s after %d attempts: %v", e.Reason, e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RetryError) Is(target error) bool {
+	return e.Reason == target
+}
+
+// RetryBudget limits retries to a ratio of the requests, shared by all retryers using it.
+// Each request deposits ratio tokens and each retry withdraws one token
+type RetryBudget struct {
+	mtx    sync.Mutex
+	ratio  float64
+	max    float64
+	tokens float64
+}
+
+// NewRetryBudget allows retries for ratio of the requests (e.g. 0.1), with up to max retries in reserve
+func NewRetryBudget(ratio float64, max int) *RetryBudget {
+	return &RetryBudget{ratio: ratio, max: float64(max), tokens: float64(max)}
+}
+
+func (b *RetryBudget) deposit() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.tokens = math.Min(b.max, b.tokens+b.ratio)
+}
+
+func (b *RetryBudget) withdraw() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.tokens < 1 {
+		return false
 	}
+	b.tokens--
+	return true
 }
 
 type logReaderCloser struct {
 	n      int
+	closed bool
 	rc     io.ReadCloser
 	logger func(n int)
 }
@@ -190,7 +408,10 @@ func (l *logReaderCloser) Read(p []byte) (n int, err error) {
 }
 
 func (l *logReaderCloser) Close() error {
-	l.logger(l.n)
+	if !l.closed {
+		l.closed = true
+		l.logger(l.n)
+	}
 	return l.rc.Close()
 }
 
@@ -206,18 +427,49 @@ func (r Backoff) Next(resp *http.Response) time.Duration {
 	return r(resp)
 }
 
-var DefaultSharedBackoff = Backoff(func(resp *http.Response) time.Duration {
-	if resp.StatusCode == http.StatusTooManyRequests {
+var DefaultSharedBackoff = RetryAfterBackoff(0)
+
+// RetryAfterBackoff honors the wait instructed by the server, capped at max (no cap if max is 0).
+// Retry-After is read on 429, 503, 301 and 302, and RateLimit-Reset/X-RateLimit-Reset
+// on 429, 503 and when the remaining rate limit is 0
+func RetryAfterBackoff(max time.Duration) Backoff {
+	return func(resp *http.Response) time.Duration {
+		if d := serverDelay(resp, time.Now()); d > 0 {
+			return capDelay(d, max)
+		}
+		return 0
+	}
+}
+
+func serverDelay(resp *http.Response, now time.Time) time.Duration {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusMovedPermanently, http.StatusFound:
 		if s := resp.Header.Get("Retry-After"); s != "" {
 			if sleep, err := strconv.ParseInt(s, 10, 64); err == nil {
 				return time.Second * time.Duration(sleep)
-			} else if after, err := time.Parse(time.RFC1123, s); err == nil {
-				return time.Until(after)
+			} else if after, err := http.ParseTime(s); err == nil { // IMF-fixdate, RFC850 and asctime
+				return after.Sub(now)
+			}
+		}
+	}
+
+	limited := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-"} {
+		if !limited && resp.Header.Get(prefix+"Remaining") != "0" {
+			continue
+		}
+		if reset, err := strconv.ParseFloat(resp.Header.Get(prefix+"Reset"), 64); err == nil {
+			if reset > epochResetThreshold {
+				return time.Unix(0, int64(reset*float64(time.Second))).Sub(now)
 			}
+			return time.Duration(reset * float64(time.Second))
 		}
 	}
 	return 0
-})
+}
+
+// epochResetThreshold separates rate limit resets given as epoch seconds from resets given as delta seconds
+const epochResetThreshold = 1e9
 
 func DefaultRetryPolicy(resp *http.Response, err error) (bool, error) {
 	if err != nil {
@@ -227,13 +479,13 @@ func DefaultRetryPolicy(resp *http.Response, err error) (bool, error) {
 }
 
 func retryStatus(resp *http.Response) (bool, error) {
-	if resp.StatusCode == 200 {
+	if statusValidator(resp.Request)(resp.StatusCode) {
 		return false, nil
 	} else if resp.StatusCode == http.StatusTooManyRequests {
-		return true, fmt.Errorf("too many requests")
+		return true, newStatusError(resp)
 	}
 	retry := resp.StatusCode == 0 || (resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented)
-	return retry, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	return retry, newStatusError(resp)
 }
 
 var (
@@ -243,6 +495,17 @@ var (
 )
 
 func retryErr(resp *http.Response, err error) (bool, error) {
+	// Don't retry if a wrapped doer can not make it before the deadline.
+	if errors.Is(err, errDeadlineBeforeNext) {
+		return false, err
+	}
+
+	// Don't retry while the circuit is open.
+	var circuitErr *CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		return false, err
+	}
+
 	if v, ok := err.(*url.Error); ok {
 		// Don't retry if the error was due to too many redirects.
 		if redirectsErrorRe.MatchString(v.Error()) {
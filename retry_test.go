@@ -1,10 +1,14 @@
 package requests_test
 
 import (
+	"errors"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -89,3 +93,289 @@ func TestNewRetryerWithRetryPolicy_Do(t *testing.T) {
 		is.Equal(resp.Header("foo"), "bar")
 	})
 }
+
+func TestRetryer_ValidateStatus(t *testing.T) {
+	var attempt int
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempt++
+		w.WriteHeader(http.StatusAccepted)
+		io.Copy(w, r.Body)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		req := requests.NewPost(url).
+			JSONBody("hello").
+			ValidateStatus(requests.StatusClass(2)).
+			WithExtended(func(req *requests.ExtendedRequest) {
+				req.Doer(doer)
+			})
+
+		resp, err := req.ExecJSON()
+		is.NoErr(err)
+		is.Equal(resp.String(), "hello")
+		is.Equal(attempt, 1)
+	})
+}
+
+func TestBackoffStrategies(t *testing.T) {
+	is := is.New(t)
+	next := func(strategy requests.BackoffStrategy, n int) []time.Duration {
+		b := strategy()
+		var out []time.Duration
+		for i := 0; i < n; i++ {
+			out = append(out, b.Next(nil))
+		}
+		return out
+	}
+
+	ms := time.Millisecond
+	is.Equal(next(requests.ConstantBackoff(ms), 3), []time.Duration{ms, ms, ms})
+	is.Equal(next(requests.LinearBackoff(ms, 2*ms), 3), []time.Duration{ms, 2 * ms, 2 * ms})
+	is.Equal(next(requests.ExponentialBackoff(ms, 5*ms, requests.NoJitter), 4), []time.Duration{ms, 2 * ms, 4 * ms, 5 * ms})
+	is.Equal(next(requests.ExponentialBackoff(ms, 0, requests.NoJitter), 100)[99], time.Duration(1<<63-1))
+
+	upper := func(attempt int) time.Duration {
+		if d := 10 * ms << attempt; d < 80*ms {
+			return d
+		}
+		return 80 * ms
+	}
+	for i := 0; i < 100; i++ {
+		for j, d := range next(requests.ExponentialBackoff(10*ms, 80*ms, requests.FullJitter), 5) {
+			is.True(d >= 0 && d < upper(j))
+		}
+		for j, d := range next(requests.ExponentialBackoff(10*ms, 80*ms, requests.EqualJitter), 5) {
+			is.True(d >= upper(j)/2 && d < upper(j))
+		}
+		for _, d := range next(requests.ExponentialBackoff(10*ms, 80*ms, requests.DecorrelatedJitter), 10) {
+			is.True(d >= 10*ms && d <= 80*ms)
+		}
+	}
+}
+
+func TestRetryer_WithBackoff(t *testing.T) {
+	var attempt int
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempt++
+		if attempt < 4 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.Copy(w, r.Body)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		start := time.Now()
+		resp, err := requests.NewPost(url).JSONBody("hello").Idempotent().WithExtended(func(req *requests.ExtendedRequest) {
+			req.Doer(requests.NewRetryer(http.DefaultClient, logger, requests.WithBackoff(requests.ConstantBackoff(time.Millisecond))))
+		}).ExecJSON()
+		is.NoErr(err)
+		is.Equal(resp.String(), "hello")
+		is.True(time.Since(start) < 500*time.Millisecond) // the default backoff sleeps at least 700ms
+	})
+}
+
+func TestRetryer_GiveUp(t *testing.T) {
+	var attempts int32
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, func(t *testing.T, url string) {
+		fastRetries := requests.WithBackoff(requests.ConstantBackoff(time.Millisecond))
+		exec := func(opts ...requests.RetryerOption) error {
+			_, err := requests.NewGet(url).WithExtended(func(req *requests.ExtendedRequest) {
+				req.Doer(requests.NewRetryer(http.DefaultClient, logger, append(opts, fastRetries)...))
+			}).ExecJSON()
+			return err
+		}
+
+		t.Run("max attempts", func(t *testing.T) {
+			is := is.New(t)
+			atomic.StoreInt32(&attempts, 0)
+			err := exec(requests.WithMaxAttempts(3))
+			is.True(errors.Is(err, requests.ErrMaxAttempts))
+			var retryErr *requests.RetryError
+			is.True(errors.As(err, &retryErr))
+			is.Equal(retryErr.Attempts, 3)
+			var statusErr *requests.StatusError
+			is.True(errors.As(err, &statusErr))
+			is.Equal(statusErr.StatusCode, http.StatusServiceUnavailable)
+			is.Equal(err.Error(), "max attempts reached after 3 attempts: invalid status 503 Service Unavailable")
+			is.Equal(atomic.LoadInt32(&attempts), int32(3))
+		})
+
+		t.Run("max elapsed", func(t *testing.T) {
+			is := is.New(t)
+			err := exec(requests.WithMaxElapsed(50 * time.Millisecond))
+			is.True(errors.Is(err, requests.ErrMaxElapsed))
+		})
+
+		t.Run("budget", func(t *testing.T) {
+			is := is.New(t)
+			atomic.StoreInt32(&attempts, 0)
+			budget := requests.NewRetryBudget(0.1, 2)
+			err := exec(requests.WithRetryBudget(budget))
+			is.True(errors.Is(err, requests.ErrRetryBudget))
+			is.Equal(atomic.LoadInt32(&attempts), int32(3))
+
+			// the budget is shared, no retries are left
+			atomic.StoreInt32(&attempts, 0)
+			err = exec(requests.WithRetryBudget(budget))
+			is.True(errors.Is(err, requests.ErrRetryBudget))
+			is.Equal(atomic.LoadInt32(&attempts), int32(1))
+		})
+	})
+}
+
+func TestRetryer_Idempotency(t *testing.T) {
+	var keys []string
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(requests.IdempotencyKeyHeader))
+		if len(keys)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.Copy(w, r.Body)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		retryer := requests.NewRetryer(http.DefaultClient, logger, requests.WithBackoff(requests.ConstantBackoff(time.Millisecond)))
+		newPost := func() *requests.Request {
+			return requests.NewPost(url).JSONBody("hello").WithExtended(func(req *requests.ExtendedRequest) {
+				req.Doer(retryer)
+			})
+		}
+
+		// POST is not retried
+		_, err := newPost().ExecJSON()
+		var statusErr *requests.StatusError
+		is.True(errors.As(err, &statusErr))
+		is.Equal(statusErr.StatusCode, http.StatusServiceUnavailable)
+		is.Equal(keys, []string{""})
+
+		// the generated key is reused by all attempts, and regenerated per execution
+		keys = nil
+		req := newPost().Idempotent()
+		for i := 0; i < 2; i++ {
+			resp, err := req.ExecJSON()
+			is.NoErr(err)
+			is.Equal(resp.String(), "hello")
+		}
+		is.Equal(len(keys), 4)
+		is.Equal(len(keys[0]), 36)
+		is.Equal(keys[0], keys[1])
+		is.Equal(keys[2], keys[3])
+		is.True(keys[0] != keys[2])
+
+		keys = nil
+		_, err = newPost().IdempotencyKey("my-key").ExecJSON()
+		is.NoErr(err)
+		is.Equal(keys, []string{"my-key", "my-key"})
+	})
+}
+
+func TestRetryAfterBackoff(t *testing.T) {
+	in := time.Now().Add(30 * time.Second).UTC()
+	tests := []struct {
+		name   string
+		status int
+		header map[string]string
+		want   time.Duration
+	}{
+		{name: "503 seconds", status: 503, header: map[string]string{"Retry-After": "2"}, want: 2 * time.Second},
+		{name: "302 seconds", status: 302, header: map[string]string{"Retry-After": "1"}, want: time.Second},
+		{name: "404 ignored", status: 404, header: map[string]string{"Retry-After": "1"}},
+		{name: "IMF-fixdate", status: 429, header: map[string]string{"Retry-After": in.Format(http.TimeFormat)}, want: 30 * time.Second},
+		{name: "RFC850", status: 429, header: map[string]string{"Retry-After": in.Format(time.RFC850)}, want: 30 * time.Second},
+		{name: "asctime", status: 429, header: map[string]string{"Retry-After": in.Format(time.ANSIC)}, want: 30 * time.Second},
+		{name: "reset epoch", status: 429, header: map[string]string{"X-RateLimit-Reset": strconv.FormatInt(in.Unix(), 10)}, want: 30 * time.Second},
+		{name: "reset delta", status: 503, header: map[string]string{"RateLimit-Reset": "5"}, want: 5 * time.Second},
+		{name: "reset remaining 0", status: 200, header: map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "5"}, want: 5 * time.Second},
+		{name: "reset remaining 10", status: 200, header: map[string]string{"RateLimit-Remaining": "10", "RateLimit-Reset": "5"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := is.New(t)
+			resp := &http.Response{StatusCode: test.status, Header: http.Header{}}
+			for k, v := range test.header {
+				resp.Header.Set(k, v)
+			}
+			got := requests.DefaultSharedBackoff.Next(resp)
+			is.True(got > test.want-2*time.Second || test.want == 0)
+			is.True(got <= test.want)
+		})
+	}
+
+	is := is.New(t)
+	resp := &http.Response{StatusCode: 429, Header: http.Header{"Retry-After": []string{"100"}}}
+	is.Equal(requests.RetryAfterBackoff(time.Second).Next(resp), time.Second)
+}
+
+func TestRetryer_SharedBackoffPerHost(t *testing.T) {
+	var limited int32
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&limited, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		io.Copy(w, r.Body)
+	}, func(t *testing.T, urlA string) {
+		withTestServer(t, echoHandler, func(t *testing.T, urlB string) {
+			is := is.New(t)
+			retryer := requests.NewRetryer(http.DefaultClient, logger,
+				requests.WithBackoff(requests.ConstantBackoff(time.Millisecond)),
+				requests.WithSharedBackoff(requests.Backoff(func(resp *http.Response) time.Duration {
+					if resp.StatusCode == http.StatusTooManyRequests {
+						return time.Second
+					}
+					return 0
+				})),
+			)
+			exec := func(url string) error {
+				resp, err := requests.NewGet(url).JSONBody("hello").WithExtended(func(req *requests.ExtendedRequest) {
+					req.Doer(retryer)
+				}).ExecJSON()
+				if err == nil && resp.String() != "hello" {
+					err = errors.New("unexpected body")
+				}
+				return err
+			}
+
+			done := make(chan error)
+			go func() {
+				done <- exec(urlA)
+			}()
+
+			hostA, hostB := strings.TrimPrefix(urlA, "http://"), strings.TrimPrefix(urlB, "http://")
+			for retryer.NextTry(hostA).IsZero() {
+				time.Sleep(time.Millisecond)
+			}
+			is.True(retryer.NextTry(hostB).IsZero())
+			is.Equal(len(retryer.NextTries()), 1)
+
+			is.NoErr(exec(urlB))
+			is.True(time.Now().Before(retryer.NextTry(hostA))) // B did not wait for the gate of A
+
+			is.NoErr(<-done)
+			is.Equal(atomic.LoadInt32(&limited), int32(2))
+		})
+	})
+}
+
+func TestRetryer_BodyWithoutGetBody(t *testing.T) {
+	var bodies []string
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if bodies = append(bodies, string(b)); len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		retryer := requests.NewRetryer(http.DefaultClient, logger, requests.WithBackoff(requests.ConstantBackoff(time.Millisecond)))
+		req, err := http.NewRequest(http.MethodPut, url, io.NopCloser(strings.NewReader("payload")))
+		is.NoErr(err)
+		is.True(req.GetBody == nil)
+
+		_, err = retryer.Do(req)
+		is.True(err != nil)
+		is.Equal(err.Error(), "can not reset body")
+		is.Equal(bodies, []string{"payload"}) // never resent with an empty body
+	})
+}
@@ -0,0 +1,152 @@
+//go:build go1.21
+
+package requests
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+type slogOptions struct {
+	attemptLevel slog.Level
+	retryLevel   slog.Level
+	failureLevel slog.Level
+	successLevel slog.Level
+}
+
+type SlogOption func(*slogOptions)
+
+// WithAttemptLevel sets the level of per attempt records, it defaults to slog.LevelDebug
+func WithAttemptLevel(level slog.Level) SlogOption {
+	return func(o *slogOptions) {
+		o.attemptLevel = level
+	}
+}
+
+// WithRetryLevel sets the level of retry records, it defaults to slog.LevelWarn
+func WithRetryLevel(level slog.Level) SlogOption {
+	return func(o *slogOptions) {
+		o.retryLevel = level
+	}
+}
+
+// WithFailureLevel sets the level of failed requests, it defaults to slog.LevelError
+func WithFailureLevel(level slog.Level) SlogOption {
+	return func(o *slogOptions) {
+		o.failureLevel = level
+	}
+}
+
+// WithSuccessLevel sets the level of successful requests, it defaults to slog.LevelInfo
+func WithSuccessLevel(level slog.Level) SlogOption {
+	return func(o *slogOptions) {
+		o.successLevel = level
+	}
+}
+
+func newSlogOptions(opts []SlogOption) slogOptions {
+	o := slogOptions{
+		attemptLevel: slog.LevelDebug,
+		retryLevel:   slog.LevelWarn,
+		failureLevel: slog.LevelError,
+		successLevel: slog.LevelInfo,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// SlogLogger is a RequestLogger writing the Retryer messages as slog records with the request id
+type SlogLogger struct {
+	logger *slog.Logger
+	opts   slogOptions
+	id     int
+	mtx    sync.Mutex
+}
+
+// NewSlogLogger returns a RequestLogger writing to logger.
+// Use SlogHook for records with the request attributes
+func NewSlogLogger(logger *slog.Logger, opts ...SlogOption) *SlogLogger {
+	return &SlogLogger{logger: logger, opts: newSlogOptions(opts)}
+}
+
+func (l *SlogLogger) NextID() int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.id++
+	return l.id
+}
+
+func (l *SlogLogger) Log(id int, err error, msg string) {
+	level := l.opts.attemptLevel
+	switch {
+	case msg == "done" && err != nil:
+		level = l.opts.failureLevel
+	case msg == "done":
+		level = l.opts.successLevel
+	case strings.HasPrefix(msg, "retry"):
+		level = l.opts.retryLevel
+	}
+
+	attrs := []slog.Attr{slog.Int("request_id", id)}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// SlogHook returns a Hook writing the request lifecycle events as slog records.
+// The url is masked, use it with WithHooks on a Retryer to get retry and done records:
+//
+//	requests.NewRetryer(doer, nil, requests.WithHooks(requests.SlogHook(slog.Default())))
+func SlogHook(logger *slog.Logger, opts ...SlogOption) Hook {
+	o := newSlogOptions(opts)
+	return func(ev Event) {
+		level := o.attemptLevel
+		switch {
+		case ev.Type == Retry:
+			level = o.retryLevel
+		case ev.Type == Done && ev.Err != nil:
+			level = o.failureLevel
+		case ev.Type == Done:
+			level = o.successLevel
+		}
+
+		ctx := ev.Request.Context()
+		if !logger.Enabled(ctx, level) {
+			return
+		}
+		logger.LogAttrs(ctx, level, "request "+ev.Type.String(), eventAttrs(ev)...)
+	}
+}
+
+func eventAttrs(ev Event) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.Int("request_id", ev.ID),
+		slog.String("method", ev.Method),
+	}
+	if u, err := url.Parse(ev.URL); err == nil {
+		attrs = append(attrs, slog.String("host", u.Host), slog.String("path", u.Path))
+		if u.RawQuery != "" {
+			attrs = append(attrs, slog.String("query", u.RawQuery))
+		}
+	}
+	attrs = append(attrs, slog.Int("attempt", ev.Attempt))
+	if ev.Status != 0 {
+		attrs = append(attrs, slog.Int("status", ev.Status))
+	}
+	if ev.Type != BeforeAttempt {
+		attrs = append(attrs, slog.Duration("latency", ev.Duration))
+	}
+	if ev.Type == BodyClosed {
+		attrs = append(attrs, slog.Int("bytes", ev.Bytes))
+	}
+	if ev.Err != nil {
+		attrs = append(attrs, slog.String("error", ev.Err.Error()))
+	}
+	return attrs
+}
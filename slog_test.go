@@ -0,0 +1,78 @@
+//go:build go1.21
+
+package requests_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ajzo90/go-requests"
+	"github.com/matryer/is"
+)
+
+func TestSlogHook(t *testing.T) {
+	var attempt int
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempt++
+		if attempt < 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		io.Copy(w, r.Body)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		buf := &bytes.Buffer{}
+		logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		retryer := requests.NewRetryer(http.DefaultClient, nil,
+			requests.WithBackoff(requests.ConstantBackoff(time.Millisecond)),
+			requests.WithHooks(requests.SlogHook(logger)),
+		)
+
+		_, err := requests.NewGet(url).Path("/users/${key}").Query("token", "${key}").JSONBody("hello").WithExtended(func(req *requests.ExtendedRequest) {
+			req.Secret("key", "secret")
+			req.Doer(retryer)
+		}).ExecJSON()
+		is.NoErr(err)
+
+		var records []map[string]interface{}
+		dec := json.NewDecoder(buf)
+		for dec.More() {
+			var record map[string]interface{}
+			is.NoErr(dec.Decode(&record))
+			delete(record, "time")
+			delete(record, "latency")
+			records = append(records, record)
+		}
+		is.Equal(len(records), 2)
+		is.Equal(records[0], map[string]interface{}{
+			"level":      "WARN",
+			"msg":        "request retry",
+			"request_id": 1.0,
+			"method":     "GET",
+			"host":       url[len("http://"):],
+			"path":       "/users/xxxxxx",
+			"query":      "token=xxxxxx",
+			"attempt":    1.0,
+			"status":     502.0,
+			"error":      "invalid status 502 Bad Gateway",
+		})
+		is.Equal(records[1]["msg"], "request done")
+		is.Equal(records[1]["level"], "INFO")
+		is.Equal(records[1]["attempt"], 2.0)
+	})
+}
+
+func TestSlogLogger(t *testing.T) {
+	is := is.New(t)
+	buf := &bytes.Buffer{}
+	logger := requests.NewSlogLogger(slog.New(slog.NewTextHandler(buf, nil)), requests.WithFailureLevel(slog.LevelWarn))
+
+	id := logger.NextID()
+	logger.Log(id, io.ErrUnexpectedEOF, "done")
+	is.True(bytes.Contains(buf.Bytes(), []byte(`level=WARN msg=done request_id=1 error="unexpected EOF"`)))
+}
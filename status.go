@@ -0,0 +1,86 @@
+package requests
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// StatusValidator reports whether a response status code is accepted
+type StatusValidator func(status int) bool
+
+// StatusCodes accepts the listed status codes
+func StatusCodes(codes ...int) StatusValidator {
+	return func(status int) bool {
+		for _, code := range codes {
+			if code == status {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// StatusRange accepts status codes in the closed interval [min, max]
+func StatusRange(min, max int) StatusValidator {
+	return func(status int) bool {
+		return status >= min && status <= max
+	}
+}
+
+// StatusClass accepts all status codes in a class, e.g. StatusClass(2) accepts 2xx
+func StatusClass(class int) StatusValidator {
+	return StatusRange(class*100, class*100+99)
+}
+
+// DefaultStatusValidator is used when no validator is set on the request
+var DefaultStatusValidator = StatusCodes(http.StatusOK)
+
+// ValidateStatus sets the validator deciding which response statuses are successful
+func (req *Request) ValidateStatus(v StatusValidator) *Request {
+	req.statusValidator = v
+	return req
+}
+
+// MaxStatusErrorBody is the max number of body bytes kept in a *StatusError
+const MaxStatusErrorBody = 512
+
+// StatusError is returned when the response status is rejected by the StatusValidator
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Method     string
+	URL        string // masked
+	Body       []byte // the first MaxStatusErrorBody bytes of the body
+}
+
+func (e *StatusError) Error() string {
+	return "invalid status " + e.Status
+}
+
+// newStatusError peeks at the body without consuming it, the caller is still responsible to drain/close it
+func newStatusError(resp *http.Response) *StatusError {
+	var body []byte
+	if resp.Body != nil {
+		body, _ = io.ReadAll(io.LimitReader(resp.Body, MaxStatusErrorBody))
+		resp.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+	}
+	var method string
+	if resp.Request != nil {
+		method = resp.Request.Method
+	}
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Method:     method,
+		URL:        maskedURL(resp.Request),
+		Body:       body,
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,122 @@
+package requests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/valyala/fastjson"
+)
+
+// ExecStream executes the request and returns the body without buffering it.
+// The status is already validated, the caller must close the body
+func (req *ExtendedRequest) ExecStream(ctxs ...context.Context) (io.ReadCloser, error) {
+	resp, err := req.Do(ctxs...)
+	if err != nil {
+		return nil, err
+	}
+	if logger := req.logger; logger != nil {
+		id := logger.NextID()
+		return &logReaderCloser{rc: resp.Body, logger: func(n int) {
+			logger.Log(id, nil, fmt.Sprintf("close %d", n))
+		}}, nil
+	}
+	return resp.Body, nil
+}
+
+// ExecTo executes the request and copies the body into w
+func (req *ExtendedRequest) ExecTo(w io.Writer, ctxs ...context.Context) (int64, error) {
+	body, err := req.ExecStream(ctxs...)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	return io.Copy(w, body)
+}
+
+// ExecToFile executes the request and writes the body to the file at path.
+// The body is written to a temporary file that replaces path on success, so a failed
+// request leaves an existing file untouched
+func (req *ExtendedRequest) ExecToFile(path string, ctxs ...context.Context) (int64, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name()) // no-op after the rename
+
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	n, err := req.ExecTo(f, ctxs...)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	return n, err
+}
+
+// ErrStop can be returned from the ExecJSONLines callback to stop reading without error
+var ErrStop = errors.New("stop")
+
+const applicationNDJSON = "application/x-ndjson"
+
+// ExecJSONLines executes the request and calls fn for each record in the newline-delimited JSON body.
+// The body is not buffered, and the *fastjson.Value is only valid until fn returns
+func (req *Request) ExecJSONLines(fn func(*fastjson.Value) error, ctxs ...context.Context) error {
+	body, err := req.Header("accept", applicationNDJSON).Extended().ExecStream(ctxs...)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var (
+		p    fastjson.Parser
+		r    = bufio.NewReader(body)
+		line []byte
+	)
+	for n := 1; ; n++ {
+		line, err = readLine(r, line[:0])
+		if err != nil && err != io.EOF {
+			return err
+		}
+		eof := err == io.EOF
+
+		if record := bytes.TrimSpace(line); len(record) > 0 {
+			v, parseErr := p.ParseBytes(record)
+			if parseErr != nil {
+				return fmt.Errorf("line %d: %w", n, parseErr)
+			}
+			if fnErr := fn(v); errors.Is(fnErr, ErrStop) {
+				return nil
+			} else if fnErr != nil {
+				return fmt.Errorf("line %d: %w", n, fnErr)
+			}
+		}
+
+		if eof {
+			return nil
+		}
+	}
+}
+
+// readLine appends the next line, of any length, to buf
+func readLine(r *bufio.Reader, buf []byte) ([]byte, error) {
+	for {
+		b, err := r.ReadSlice('\n')
+		buf = append(buf, b...)
+		if err != bufio.ErrBufferFull {
+			return buf, err
+		}
+	}
+}
@@ -14,6 +14,26 @@ func (req *Request) JSONBody(value interface{}) *Request {
 	})
 }
 
+// FormBody adds a form value to an application/x-www-form-urlencoded body,
+// value accepts the same lazy values as Header and Query
+func (req *Request) FormBody(key string, value interface{}) *Request {
+	form := req.form
+	if form == nil {
+		form = stringerMap{}
+	}
+	form[key] = req.toStringer(value)
+	req.replaceBody(formBody(form)).form = form
+	return req.ContentType(applicationForm)
+}
+
+// FormValues adds the form values to an application/x-www-form-urlencoded body
+func (req *Request) FormValues(values map[string]interface{}) *Request {
+	for k, v := range values {
+		req.FormBody(k, v)
+	}
+	return req.ContentType(applicationForm)
+}
+
 func basicAuth(username, password string) string {
 	auth := username + ":" + password
 	return base64.StdEncoding.EncodeToString([]byte(auth))
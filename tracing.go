@@ -0,0 +1,202 @@
+package requests
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// W3C trace context headers
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// SpanContext identifies a span in a trace, it follows the W3C trace context
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Sampled    bool
+	TraceState string
+}
+
+// IsValid reports whether both the trace and span id are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent formats the traceparent header value, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// ParseTraceParent parses a traceparent header value
+func ParseTraceParent(s string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	var flags [1]byte
+	if hex.DecodedLen(len(parts[1])) != len(sc.TraceID) || hex.DecodedLen(len(parts[2])) != len(sc.SpanID) || len(parts[3]) != 2 {
+		return sc, false
+	} else if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	} else if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	} else if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+// SpanContextFromRequest extracts the span context of an incoming request, e.g. in a server handler
+func SpanContextFromRequest(r *http.Request) (SpanContext, bool) {
+	sc, ok := ParseTraceParent(r.Header.Get(TraceParentHeader))
+	if ok {
+		sc.TraceState = r.Header.Get(TraceStateHeader)
+	}
+	return sc, ok
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext sets the parent of the spans created by the Tracer
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the current span context
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Span is a finished client span
+type Span struct {
+	Name         string
+	SpanContext  SpanContext
+	ParentSpanID [8]byte // zero for root spans
+	Start, End   time.Time
+	Attributes   map[string]interface{}
+	Err          error
+}
+
+// SpanExporter receives the finished and sampled spans
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// InMemoryExporter keeps the exported spans in memory, e.g. for tests
+type InMemoryExporter struct {
+	mtx   sync.Mutex
+	spans []*Span
+}
+
+func (e *InMemoryExporter) ExportSpan(span *Span) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the exported spans in the order they ended
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+// Reset drops the exported spans
+func (e *InMemoryExporter) Reset() {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.spans = nil
+}
+
+// Tracer creates client spans and propagates them with the W3C traceparent/tracestate headers.
+// Attributes use the masked url, so secrets never end up in traces
+//
+//	tracer := requests.NewTracer(exporter)
+//	retryer := requests.NewRetryer(requests.Chain(http.DefaultClient, tracer.AttemptSpans()), logger)
+//	doer := requests.Chain(retryer, tracer.ClientSpans())
+type Tracer struct {
+	exporter SpanExporter
+}
+
+func NewTracer(exporter SpanExporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// ClientSpans is a Middleware creating one span per logical request, place it outside the Retryer
+func (t *Tracer) ClientSpans() Middleware {
+	return t.middleware(false)
+}
+
+// AttemptSpans is a Middleware creating one span per attempt, place it inside the Retryer
+func (t *Tracer) AttemptSpans() Middleware {
+	return t.middleware(true)
+}
+
+func (t *Tracer) middleware(attempt bool) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			span := t.start(r, attempt)
+			r = r.Clone(ContextWithSpanContext(r.Context(), span.SpanContext))
+			r.Header.Set(TraceParentHeader, span.SpanContext.TraceParent())
+			if ts := span.SpanContext.TraceState; ts != "" {
+				r.Header.Set(TraceStateHeader, ts)
+			}
+
+			resp, err := next.Do(r)
+			if status := responseStatus(resp, err); status != 0 {
+				span.Attributes["http.response.status_code"] = status
+			}
+			if attempt {
+				span.Attributes["http.request.resend_count"] = Attempt(r) - 1
+			}
+			span.Err = err
+			t.end(span)
+			return resp, err
+		})
+	}
+}
+
+func (t *Tracer) start(r *http.Request, attempt bool) *Span {
+	span := &Span{Name: "HTTP " + r.Method, Start: time.Now()}
+	if attempt {
+		span.Name += " attempt"
+	}
+	if parent, ok := SpanContextFromContext(r.Context()); ok && parent.IsValid() {
+		span.SpanContext = parent
+		span.ParentSpanID = parent.SpanID
+	} else {
+		span.SpanContext = SpanContext{Sampled: true}
+		randomBytes(span.SpanContext.TraceID[:])
+	}
+	randomBytes(span.SpanContext.SpanID[:])
+
+	span.Attributes = map[string]interface{}{
+		"http.request.method": r.Method,
+		"url.full":            maskedURL(r),
+	}
+	if u, err := url.Parse(maskedURL(r)); err == nil {
+		span.Attributes["server.address"] = u.Hostname()
+	}
+	return span
+}
+
+func (t *Tracer) end(span *Span) {
+	span.End = time.Now()
+	if span.SpanContext.Sampled && t.exporter != nil {
+		t.exporter.ExportSpan(span)
+	}
+}
@@ -0,0 +1,86 @@
+package requests_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ajzo90/go-requests"
+	"github.com/matryer/is"
+)
+
+func TestTracer(t *testing.T) {
+	var traceparents, tracestates []string
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get(requests.TraceParentHeader))
+		tracestates = append(tracestates, r.Header.Get(requests.TraceStateHeader))
+		if len(traceparents) < 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		io.Copy(w, r.Body)
+	}, func(t *testing.T, url string) {
+		is := is.New(t)
+		exporter := &requests.InMemoryExporter{}
+		tracer := requests.NewTracer(exporter)
+		retryer := requests.NewRetryer(requests.Chain(http.DefaultClient, tracer.AttemptSpans()), nil,
+			requests.WithBackoff(requests.ConstantBackoff(time.Millisecond)))
+		doer := requests.Chain(retryer, tracer.ClientSpans())
+
+		parent, ok := requests.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		is.True(ok)
+		parent.TraceState = "vendor=1"
+		ctx := requests.ContextWithSpanContext(context.Background(), parent)
+
+		_, err := requests.NewGet(url).Query("token", "${key}").JSONBody("hello").WithExtended(func(req *requests.ExtendedRequest) {
+			req.Secret("key", "secret")
+			req.Doer(doer)
+		}).ExecJSON(ctx)
+		is.NoErr(err)
+
+		is.Equal(tracestates, []string{"vendor=1", "vendor=1"})
+
+		spans := exporter.Spans()
+		is.Equal(len(spans), 3)
+		first, second, client := spans[0], spans[1], spans[2]
+
+		is.Equal(client.Name, "HTTP GET")
+		is.Equal(client.SpanContext.TraceID, parent.TraceID)
+		is.Equal(client.ParentSpanID, parent.SpanID)
+		is.Equal(client.Attributes["url.full"], url+"?token=xxxxxx")
+		is.Equal(client.Attributes["http.response.status_code"], 200)
+
+		for i, span := range []*requests.Span{first, second} {
+			is.Equal(span.Name, "HTTP GET attempt")
+			is.Equal(span.SpanContext.TraceID, parent.TraceID)
+			is.Equal(span.ParentSpanID, client.SpanContext.SpanID)
+			is.Equal(span.Attributes["http.request.resend_count"], i)
+			is.Equal(traceparents[i], span.SpanContext.TraceParent())
+		}
+		is.Equal(first.Attributes["http.response.status_code"], 500)
+		is.True(first.SpanContext.SpanID != second.SpanContext.SpanID)
+
+		sc, ok := requests.ParseTraceParent(traceparents[1])
+		is.True(ok)
+		is.Equal(sc, requests.SpanContext{TraceID: parent.TraceID, SpanID: second.SpanContext.SpanID, Sampled: true})
+	})
+}
+
+func TestParseTraceParent(t *testing.T) {
+	is := is.New(t)
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bx-01",
+	} {
+		_, ok := requests.ParseTraceParent(s)
+		is.True(!ok)
+	}
+	sc, ok := requests.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	is.True(ok)
+	is.True(!sc.Sampled)
+}